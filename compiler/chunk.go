@@ -0,0 +1,88 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+// Chunk is a compiled sequence of instructions together with the constants
+// they reference. Spans holds the source location of the instruction that
+// starts at each offset of Code, and is used to report runtime errors.
+type Chunk struct {
+	Code      []byte
+	Constants []any
+	Spans     []core.Span
+}
+
+// Proto is a compiled function that has not been closed over a scope yet.
+type Proto struct {
+	Name  string
+	Arity int
+	Scope *ScopeInfo
+	Chunk *Chunk
+}
+
+// ScopeInfo describes the slots allocated when a scope is entered. The
+// names are only kept for error messages.
+type ScopeInfo struct {
+	Names []string
+}
+
+func (chunk *Chunk) write(b byte, span core.Span) {
+	chunk.Code = append(chunk.Code, b)
+	chunk.Spans = append(chunk.Spans, span)
+}
+
+func (chunk *Chunk) addConstant(value any) int {
+	chunk.Constants = append(chunk.Constants, value)
+	return len(chunk.Constants) - 1
+}
+
+func (chunk *Chunk) readU16(offset int) int {
+	return int(chunk.Code[offset])<<8 | int(chunk.Code[offset+1])
+}
+
+// Disassemble renders the chunk and every function nested in it in a
+// human readable form, one instruction per line.
+func (chunk *Chunk) Disassemble(name string) string {
+	var b strings.Builder
+	chunk.disassemble(&b, name)
+	return b.String()
+}
+
+func (chunk *Chunk) disassemble(b *strings.Builder, name string) {
+	fmt.Fprintf(b, "== %s ==\n", name)
+	var nested []*Proto
+	for offset := 0; offset < len(chunk.Code); {
+		op := Opcode(chunk.Code[offset])
+		fmt.Fprintf(b, "%04d %4d %-24v", offset, chunk.Spans[offset].Line, op)
+		offset++
+
+		for _, width := range operandWidths[op] {
+			operand := int(chunk.Code[offset])
+			if width == 2 {
+				operand = chunk.readU16(offset)
+			}
+			fmt.Fprintf(b, " %d", operand)
+			offset += width
+		}
+
+		switch op {
+		case OP_CONSTANT, OP_GET_GLOBAL, OP_SET_GLOBAL, OP_DEFINE_GLOBAL, OP_IMPORT, OP_GET_MEMBER:
+			fmt.Fprintf(b, " (%v)", chunk.Constants[chunk.readU16(offset-2)])
+		case OP_CLOSURE:
+			proto := chunk.Constants[chunk.readU16(offset-2)].(*Proto)
+			fmt.Fprintf(b, " (%s)", proto.Name)
+			nested = append(nested, proto)
+		case OP_BINARY, OP_UNARY, OP_SET_INDEX:
+			fmt.Fprintf(b, " (%v)", core.TokenType(chunk.Code[offset-1]))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, proto := range nested {
+		proto.Chunk.disassemble(b, proto.Name)
+	}
+}
@@ -0,0 +1,712 @@
+package compiler
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+// CompileError reports a program that parsed but exceeds a limit of the
+// bytecode format, such as the number of constants in one function.
+type CompileError struct {
+	Span    core.Span
+	Message string
+}
+
+func (err *CompileError) Error() string {
+	return fmt.Sprintf("[ERROR] %s at %v", err.Message, err.Span)
+}
+
+func (err *CompileError) Location() core.Span {
+	return err.Span
+}
+
+// compileScope mirrors a scope the VM allocates at runtime. Scopes that
+// declare nothing are never materialized, neither here nor in the VM, so
+// the depth of a variable counts only scopes that exist at runtime.
+type compileScope struct {
+	info      *ScopeInfo
+	slots     map[string]int
+	declared  map[string]bool
+	enclosing *compileScope
+	function  *funcCompiler
+}
+
+type loopInfo struct {
+	label     string
+	breaks    []int
+	continues []int
+}
+
+type funcCompiler struct {
+	proto     *Proto
+	chunk     *Chunk
+	enclosing *funcCompiler
+	scope     *compileScope
+	loops     []*loopInfo
+}
+
+// Compile lowers a parsed program to bytecode. The returned Proto takes no
+// arguments and returns the value of the program's last expression, the
+// same value the tree-walking interpreter produces.
+func Compile(program []core.Expr) (*Proto, error) {
+	fc := &funcCompiler{
+		proto: &Proto{Name: "<script>"},
+		chunk: &Chunk{},
+	}
+	fc.proto.Chunk = fc.chunk
+
+	err := fc.sequence(program, core.Span{})
+	if err != nil {
+		return nil, err
+	}
+	fc.emit(OP_RETURN, core.Span{})
+
+	return fc.proto, nil
+}
+
+func (fc *funcCompiler) emit(op Opcode, span core.Span, operands ...byte) {
+	fc.chunk.write(byte(op), span)
+	for _, operand := range operands {
+		fc.chunk.write(operand, span)
+	}
+}
+
+func (fc *funcCompiler) u16(value int, span core.Span) ([]byte, error) {
+	if value > 0xffff {
+		return nil, &CompileError{Span: span, Message: "Too many constants in one function"}
+	}
+	return []byte{byte(value >> 8), byte(value)}, nil
+}
+
+func (fc *funcCompiler) emitConstant(op Opcode, value any, span core.Span) error {
+	operand, err := fc.u16(fc.chunk.addConstant(value), span)
+	if err != nil {
+		return err
+	}
+	fc.emit(op, span, operand...)
+	return nil
+}
+
+// emitJump emits a forward jump and returns the offset of its operand so
+// it can be patched once the target is known.
+func (fc *funcCompiler) emitJump(op Opcode, span core.Span) int {
+	fc.emit(op, span, 0xff, 0xff)
+	return len(fc.chunk.Code) - 2
+}
+
+func (fc *funcCompiler) patchJump(operand int) error {
+	return fc.patchJumpTo(operand, len(fc.chunk.Code))
+}
+
+func (fc *funcCompiler) patchJumpTo(operand int, target int) error {
+	distance := target - operand - 2
+	if distance > 0xffff {
+		return &CompileError{Span: fc.chunk.Spans[operand], Message: "Too much code to jump over"}
+	}
+	fc.chunk.Code[operand] = byte(distance >> 8)
+	fc.chunk.Code[operand+1] = byte(distance)
+	return nil
+}
+
+func (fc *funcCompiler) emitLoop(start int, span core.Span) error {
+	distance := len(fc.chunk.Code) + 3 - start
+	if distance > 0xffff {
+		return &CompileError{Span: span, Message: "Loop body too large"}
+	}
+	fc.emit(OP_LOOP, span, byte(distance>>8), byte(distance))
+	return nil
+}
+
+// sequence compiles expressions so that only the value of the last one is
+// left on the stack, or nil when there are none.
+func (fc *funcCompiler) sequence(program []core.Expr, span core.Span) error {
+	if len(program) == 0 {
+		fc.emit(OP_NIL, span)
+		return nil
+	}
+
+	for i, expr := range program {
+		if i > 0 {
+			fc.emit(OP_POP, span)
+		}
+		err := fc.compile(expr)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// declarations lists the names declared directly in the scope that
+// evaluates exprs, without descending into nested blocks or functions,
+// which get scopes of their own.
+func declarations(exprs []core.Expr) []string {
+	var names []string
+	seen := map[string]bool{}
+	var visit func(expr core.Expr)
+	visit = func(expr core.Expr) {
+		switch e := expr.(type) {
+		case core.AssignExpr:
+			if e.Operator.Type == core.COLON_EQ && !seen[e.Name.String()] {
+				seen[e.Name.String()] = true
+				names = append(names, e.Name.String())
+			}
+		case core.FnDeclExpr:
+			if !seen[e.Name.String()] {
+				seen[e.Name.String()] = true
+				names = append(names, e.Name.String())
+			}
+			return
+		case core.ImportExpr:
+			if !seen[e.Alias().String()] {
+				seen[e.Alias().String()] = true
+				names = append(names, e.Alias().String())
+			}
+		case core.BlockExpr, core.FnExpr:
+			return
+		}
+		for _, child := range children(expr) {
+			visit(child)
+		}
+	}
+	for _, expr := range exprs {
+		visit(expr)
+	}
+	return names
+}
+
+// children returns the direct subexpressions of expr in evaluation order.
+func children(expr core.Expr) []core.Expr {
+	var res []core.Expr
+	add := func(exprs ...core.Expr) {
+		for _, e := range exprs {
+			if e != nil {
+				res = append(res, e)
+			}
+		}
+	}
+
+	switch e := expr.(type) {
+	case core.AssignExpr:
+		add(e.Value)
+	case core.BinaryExpr:
+		add(e.Left, e.Right)
+	case core.LogicalExpr:
+		add(e.Left, e.Right)
+	case core.UnaryExpr:
+		add(e.Operand)
+	case core.GroupingExpr:
+		add(e.Inner)
+	case core.BlockExpr:
+		add(e.Body...)
+	case core.IfExpr:
+		add(e.Condition, e.Then, e.Else)
+	case core.WhileExpr:
+		add(e.Condition, e.Body, e.Increment)
+	case core.CallExpr:
+		add(e.Callee)
+		add(e.Args...)
+	case core.FnDeclExpr:
+		add(e.Body)
+	case core.FnExpr:
+		add(e.Body)
+	case core.ArrayInitExpr:
+		add(e.Elements...)
+	case core.IndexExpr:
+		add(e.Object, e.Index)
+	case core.IndexAssignExpr:
+		add(e.Object, e.Index, e.Value)
+	case core.MapInitExpr:
+		for i := range e.Keys {
+			add(e.Keys[i], e.Values[i])
+		}
+	case core.ReturnExpr:
+		add(e.Value)
+	case core.GetExpr:
+		add(e.Object)
+	}
+	return res
+}
+
+func (fc *funcCompiler) pushScope(names []string, declared []string) *compileScope {
+	scope := &compileScope{
+		info:      &ScopeInfo{Names: names},
+		slots:     make(map[string]int, len(names)),
+		declared:  make(map[string]bool),
+		enclosing: fc.scope,
+		function:  fc,
+	}
+	for i, name := range names {
+		scope.slots[name] = i
+	}
+	for _, name := range declared {
+		scope.declared[name] = true
+	}
+	fc.scope = scope
+	return scope
+}
+
+// resolve finds the runtime scope depth and slot of name. Within the same
+// function only names declared so far count, matching the order in which
+// the tree-walker declares them. Function bodies run later, so from inside
+// a nested function every name its enclosing scopes ever declare is
+// visible, which lets local functions call each other.
+func (fc *funcCompiler) resolve(name string) (int, int, bool) {
+	depth := 0
+	for scope := fc.scope; scope != nil; scope = scope.enclosing {
+		if slot, ok := scope.slots[name]; ok {
+			if scope.function != fc || scope.declared[name] {
+				return depth, slot, true
+			}
+		}
+		depth++
+	}
+	return 0, 0, false
+}
+
+func (fc *funcCompiler) localOperands(depth int, slot int, span core.Span) ([]byte, error) {
+	if depth > 0xff || slot > 0xff {
+		return nil, &CompileError{Span: span, Message: "Too many nested scopes or variables"}
+	}
+	return []byte{byte(depth), byte(slot)}, nil
+}
+
+func (fc *funcCompiler) emitGet(name core.Token) error {
+	span := name.Span()
+	if depth, slot, ok := fc.resolve(name.String()); ok {
+		operands, err := fc.localOperands(depth, slot, span)
+		if err != nil {
+			return err
+		}
+		fc.emit(OP_GET_LOCAL, span, operands...)
+		return nil
+	}
+	return fc.emitConstant(OP_GET_GLOBAL, name.String(), span)
+}
+
+func (fc *funcCompiler) emitSet(name core.Token) error {
+	span := name.Span()
+	if depth, slot, ok := fc.resolve(name.String()); ok {
+		operands, err := fc.localOperands(depth, slot, span)
+		if err != nil {
+			return err
+		}
+		fc.emit(OP_SET_LOCAL, span, operands...)
+		return nil
+	}
+	return fc.emitConstant(OP_SET_GLOBAL, name.String(), span)
+}
+
+// emitDeclare binds the value on top of the stack to name in the current
+// scope, or as a global at the top level.
+func (fc *funcCompiler) emitDeclare(name core.Token) error {
+	span := name.Span()
+	if fc.scope == nil {
+		return fc.emitConstant(OP_DEFINE_GLOBAL, name.String(), span)
+	}
+
+	slot := fc.scope.slots[name.String()]
+	fc.scope.declared[name.String()] = true
+	operands, err := fc.localOperands(0, slot, span)
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_SET_LOCAL, span, operands...)
+	return nil
+}
+
+func (fc *funcCompiler) compile(expr core.Expr) error {
+	switch e := expr.(type) {
+	case core.LiteralExpr:
+		return fc.literal(e)
+	case core.GroupingExpr:
+		return fc.compile(e.Inner)
+	case core.AssignExpr:
+		return fc.assign(e)
+	case core.BinaryExpr:
+		return fc.binary(e)
+	case core.LogicalExpr:
+		return fc.logical(e)
+	case core.UnaryExpr:
+		err := fc.compile(e.Operand)
+		if err != nil {
+			return err
+		}
+		fc.emit(OP_UNARY, e.Operator.Span(), byte(e.Operator.Type))
+		return nil
+	case core.BlockExpr:
+		return fc.block(e)
+	case core.IfExpr:
+		return fc.ifExpr(e)
+	case core.WhileExpr:
+		return fc.while(e)
+	case core.BreakExpr:
+		return fc.jump(e.Keyword, e.Label, true)
+	case core.ContinueExpr:
+		return fc.jump(e.Keyword, e.Label, false)
+	case core.ReturnExpr:
+		if e.Value != nil {
+			err := fc.compile(e.Value)
+			if err != nil {
+				return err
+			}
+		} else {
+			fc.emit(OP_NIL, e.Keyword.Span())
+		}
+		fc.emit(OP_RETURN, e.Keyword.Span())
+		return nil
+	case core.CallExpr:
+		return fc.call(e)
+	case core.ImportExpr:
+		err := fc.emitConstant(OP_IMPORT, e.Path.Value, e.Path.Span())
+		if err != nil {
+			return err
+		}
+		return fc.emitDeclare(e.Alias())
+	case core.GetExpr:
+		err := fc.compile(e.Object)
+		if err != nil {
+			return err
+		}
+		return fc.emitConstant(OP_GET_MEMBER, e.Name.String(), e.Name.Span())
+	case core.FnDeclExpr:
+		return fc.fnDecl(e)
+	case core.FnExpr:
+		return fc.function("<fn>", e.Params, e.Body, core.Span{})
+	case core.ArrayInitExpr:
+		for _, element := range e.Elements {
+			err := fc.compile(element)
+			if err != nil {
+				return err
+			}
+		}
+		operand, err := fc.u16(len(e.Elements), e.Bracket.Span())
+		if err != nil {
+			return err
+		}
+		fc.emit(OP_ARRAY, e.Bracket.Span(), operand...)
+		return nil
+	case core.MapInitExpr:
+		for i := range e.Keys {
+			err := fc.compile(e.Keys[i])
+			if err != nil {
+				return err
+			}
+			err = fc.compile(e.Values[i])
+			if err != nil {
+				return err
+			}
+		}
+		operand, err := fc.u16(len(e.Keys), e.Brace.Span())
+		if err != nil {
+			return err
+		}
+		fc.emit(OP_MAP, e.Brace.Span(), operand...)
+		return nil
+	case core.IndexExpr:
+		err := fc.compile(e.Object)
+		if err != nil {
+			return err
+		}
+		err = fc.compile(e.Index)
+		if err != nil {
+			return err
+		}
+		fc.emit(OP_INDEX, e.Bracket.Span())
+		return nil
+	case core.IndexAssignExpr:
+		for _, child := range []core.Expr{e.Object, e.Index, e.Value} {
+			err := fc.compile(child)
+			if err != nil {
+				return err
+			}
+		}
+		fc.emit(OP_SET_INDEX, e.Operator.Span(), byte(e.Operator.Type))
+		return nil
+	default:
+		return &CompileError{Message: fmt.Sprintf("Cannot compile %T", expr)}
+	}
+}
+
+func (fc *funcCompiler) literal(expr core.LiteralExpr) error {
+	span := expr.Token.Span()
+	switch expr.Token.Type {
+	case core.TRUE:
+		fc.emit(OP_TRUE, span)
+		return nil
+	case core.FALSE:
+		fc.emit(OP_FALSE, span)
+		return nil
+	case core.IDENTIFIER:
+		return fc.emitGet(expr.Token)
+	default:
+		return fc.emitConstant(OP_CONSTANT, expr.Token.Value, span)
+	}
+}
+
+func (fc *funcCompiler) assign(expr core.AssignExpr) error {
+	err := fc.compile(expr.Value)
+	if err != nil {
+		return err
+	}
+
+	switch expr.Operator.Type {
+	case core.COLON_EQ:
+		return fc.emitDeclare(expr.Name)
+	case core.EQUAL:
+		return fc.emitSet(expr.Name)
+	}
+
+	op, ok := core.CompoundOperator(expr.Operator.Type)
+	if !ok {
+		return &CompileError{Span: expr.Operator.Span(), Message: fmt.Sprintf("Invalid assignment operator: %s", expr.Operator.Type)}
+	}
+	// The right-hand side is evaluated before the variable is read, like
+	// the tree-walker does, so swap the two before applying op.
+	err = fc.emitGet(expr.Name)
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_SWAP, expr.Operator.Span())
+	fc.emit(OP_BINARY, expr.Operator.Span(), byte(op))
+	return fc.emitSet(expr.Name)
+}
+
+func (fc *funcCompiler) binary(expr core.BinaryExpr) error {
+	err := fc.compile(expr.Left)
+	if err != nil {
+		return err
+	}
+	err = fc.compile(expr.Right)
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_BINARY, expr.Operator.Span(), byte(expr.Operator.Type))
+	return nil
+}
+
+func (fc *funcCompiler) logical(expr core.LogicalExpr) error {
+	span := expr.Operator.Span()
+	op := byte(expr.Operator.Type)
+
+	err := fc.compile(expr.Left)
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_CHECK_LOGICAL, span, op, 0)
+
+	jump := OP_JUMP_IF_FALSE_OR_POP
+	if expr.Operator.Type == core.BAR_BAR {
+		jump = OP_JUMP_IF_TRUE_OR_POP
+	}
+	end := fc.emitJump(jump, span)
+
+	err = fc.compile(expr.Right)
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_CHECK_LOGICAL, span, op, 1)
+	return fc.patchJump(end)
+}
+
+func (fc *funcCompiler) block(expr core.BlockExpr) error {
+	names := declarations(expr.Body)
+	if len(names) == 0 {
+		return fc.sequence(expr.Body, core.Span{})
+	}
+
+	scope := fc.pushScope(names, nil)
+	err := fc.emitConstant(OP_ENTER_SCOPE, scope.info, core.Span{})
+	if err != nil {
+		return err
+	}
+	err = fc.sequence(expr.Body, core.Span{})
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_EXIT_SCOPE, core.Span{})
+	fc.scope = scope.enclosing
+	return nil
+}
+
+func (fc *funcCompiler) ifExpr(expr core.IfExpr) error {
+	err := fc.compile(expr.Condition)
+	if err != nil {
+		return err
+	}
+	elseJump := fc.emitJump(OP_JUMP_IF_FALSE, expr.Keyword.Span())
+
+	err = fc.compile(expr.Then)
+	if err != nil {
+		return err
+	}
+	endJump := fc.emitJump(OP_JUMP, expr.Keyword.Span())
+
+	err = fc.patchJump(elseJump)
+	if err != nil {
+		return err
+	}
+	if expr.Else != nil {
+		err = fc.compile(expr.Else)
+		if err != nil {
+			return err
+		}
+	} else {
+		fc.emit(OP_NIL, expr.Keyword.Span())
+	}
+	return fc.patchJump(endJump)
+}
+
+func (fc *funcCompiler) while(expr core.WhileExpr) error {
+	span := expr.Keyword.Span()
+	label, _ := expr.Label.Value.(string)
+	loop := &loopInfo{label: label}
+	fc.loops = append(fc.loops, loop)
+
+	fc.emit(OP_LOOP_ENTER, span)
+	start := len(fc.chunk.Code)
+	err := fc.compile(expr.Condition)
+	if err != nil {
+		return err
+	}
+	exitJump := fc.emitJump(OP_JUMP_IF_FALSE, span)
+
+	err = fc.compile(expr.Body)
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_POP, span)
+
+	for _, operand := range loop.continues {
+		err = fc.patchJump(operand)
+		if err != nil {
+			return err
+		}
+	}
+	if expr.Increment != nil {
+		err = fc.compile(expr.Increment)
+		if err != nil {
+			return err
+		}
+		fc.emit(OP_POP, span)
+	}
+	err = fc.emitLoop(start, span)
+	if err != nil {
+		return err
+	}
+
+	err = fc.patchJump(exitJump)
+	if err != nil {
+		return err
+	}
+	for _, operand := range loop.breaks {
+		err = fc.patchJump(operand)
+		if err != nil {
+			return err
+		}
+	}
+	fc.emit(OP_LOOP_EXIT, span)
+	fc.emit(OP_NIL, span)
+
+	fc.loops = fc.loops[:len(fc.loops)-1]
+	return nil
+}
+
+// jump compiles break and continue. OP_UNWIND drops the loops that are left
+// and restores the stack and scope of the target loop before jumping.
+func (fc *funcCompiler) jump(keyword core.Token, label core.Token, isBreak bool) error {
+	name, _ := label.Value.(string)
+	target := len(fc.loops) - 1
+	if name != "" {
+		for target >= 0 && fc.loops[target].label != name {
+			target--
+		}
+	}
+	if target < 0 {
+		return &CompileError{Span: keyword.Span(), Message: fmt.Sprintf("Cannot use %v outside of a loop", keyword)}
+	}
+
+	skipped := len(fc.loops) - 1 - target
+	if skipped > 0xff {
+		return &CompileError{Span: keyword.Span(), Message: "Too many nested loops"}
+	}
+	fc.emit(OP_UNWIND, keyword.Span(), byte(skipped))
+
+	operand := fc.emitJump(OP_JUMP, keyword.Span())
+	loop := fc.loops[target]
+	if isBreak {
+		loop.breaks = append(loop.breaks, operand)
+	} else {
+		loop.continues = append(loop.continues, operand)
+	}
+	return nil
+}
+
+func (fc *funcCompiler) call(expr core.CallExpr) error {
+	err := fc.compile(expr.Callee)
+	if err != nil {
+		return err
+	}
+	for _, arg := range expr.Args {
+		err = fc.compile(arg)
+		if err != nil {
+			return err
+		}
+	}
+
+	span := expr.Paren.Span()
+	if len(expr.Args) > 0xff {
+		return &CompileError{Span: span, Message: "Too many arguments"}
+	}
+	name, err := fc.u16(fc.chunk.addConstant(expr.Callee.String()), span)
+	if err != nil {
+		return err
+	}
+	fc.emit(OP_CALL, span, append([]byte{byte(len(expr.Args))}, name...)...)
+	return nil
+}
+
+func (fc *funcCompiler) fnDecl(expr core.FnDeclExpr) error {
+	if fc.scope != nil {
+		fc.scope.declared[expr.Name.String()] = true
+	}
+	err := fc.function(expr.Name.String(), expr.Params, expr.Body, expr.Name.Span())
+	if err != nil {
+		return err
+	}
+	return fc.emitDeclare(expr.Name)
+}
+
+// function compiles a function body into its own Proto and emits the
+// OP_CLOSURE that captures the current scope at runtime.
+func (fc *funcCompiler) function(name string, params []core.Token, body core.Expr, span core.Span) error {
+	inner := &funcCompiler{
+		proto:     &Proto{Name: name, Arity: len(params)},
+		chunk:     &Chunk{},
+		enclosing: fc,
+		scope:     fc.scope,
+	}
+	inner.proto.Chunk = inner.chunk
+
+	names := []string{}
+	for _, param := range params {
+		names = append(names, param.String())
+	}
+	for _, declared := range declarations([]core.Expr{body}) {
+		if !slices.Contains(names, declared) {
+			names = append(names, declared)
+		}
+	}
+	scope := inner.pushScope(names, names[:len(params)])
+	inner.proto.Scope = scope.info
+
+	err := inner.compile(body)
+	if err != nil {
+		return err
+	}
+	inner.emit(OP_RETURN, span)
+
+	return fc.emitConstant(OP_CLOSURE, inner.proto, span)
+}
@@ -0,0 +1,96 @@
+package compiler
+
+type Opcode byte
+
+// Operands follow the opcode in the instruction stream, u8 operands take
+// one byte and u16 operands two bytes in big endian order.
+const (
+	OP_CONSTANT Opcode = iota // u16 constant
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_SWAP
+	OP_DUP2
+
+	OP_GET_GLOBAL    // u16 name constant
+	OP_SET_GLOBAL    // u16 name constant
+	OP_DEFINE_GLOBAL // u16 name constant
+	OP_GET_LOCAL     // u8 depth, u8 slot
+	OP_SET_LOCAL     // u8 depth, u8 slot
+
+	OP_ENTER_SCOPE // u16 scope constant
+	OP_EXIT_SCOPE
+
+	OP_BINARY        // u8 operator
+	OP_UNARY         // u8 operator
+	OP_CHECK_LOGICAL // u8 operator, u8 side
+
+	OP_JUMP                 // u16 offset
+	OP_JUMP_IF_FALSE        // u16 offset
+	OP_JUMP_IF_FALSE_OR_POP // u16 offset
+	OP_JUMP_IF_TRUE_OR_POP  // u16 offset
+	OP_LOOP                 // u16 offset
+
+	OP_LOOP_ENTER
+	OP_LOOP_EXIT
+	OP_UNWIND // u8 loops
+
+	OP_CALL    // u8 argument count, u16 callee name constant
+	OP_CLOSURE // u16 proto constant
+	OP_RETURN
+
+	OP_ARRAY // u16 element count
+	OP_MAP   // u16 entry count
+	OP_INDEX
+	OP_SET_INDEX // u8 operator
+
+	OP_IMPORT     // u16 path constant
+	OP_GET_MEMBER // u16 name constant
+)
+
+var opcodeNames = [...]string{
+	"OP_CONSTANT", "OP_NIL", "OP_TRUE", "OP_FALSE", "OP_POP", "OP_SWAP", "OP_DUP2",
+	"OP_GET_GLOBAL", "OP_SET_GLOBAL", "OP_DEFINE_GLOBAL", "OP_GET_LOCAL", "OP_SET_LOCAL",
+	"OP_ENTER_SCOPE", "OP_EXIT_SCOPE",
+	"OP_BINARY", "OP_UNARY", "OP_CHECK_LOGICAL",
+	"OP_JUMP", "OP_JUMP_IF_FALSE", "OP_JUMP_IF_FALSE_OR_POP", "OP_JUMP_IF_TRUE_OR_POP", "OP_LOOP",
+	"OP_LOOP_ENTER", "OP_LOOP_EXIT", "OP_UNWIND",
+	"OP_CALL", "OP_CLOSURE", "OP_RETURN",
+	"OP_ARRAY", "OP_MAP", "OP_INDEX", "OP_SET_INDEX",
+	"OP_IMPORT", "OP_GET_MEMBER",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return "OP_UNKNOWN"
+}
+
+// operandWidths lists the byte width of each operand of an opcode.
+var operandWidths = map[Opcode][]int{
+	OP_CONSTANT:             {2},
+	OP_GET_GLOBAL:           {2},
+	OP_SET_GLOBAL:           {2},
+	OP_DEFINE_GLOBAL:        {2},
+	OP_GET_LOCAL:            {1, 1},
+	OP_SET_LOCAL:            {1, 1},
+	OP_ENTER_SCOPE:          {2},
+	OP_BINARY:               {1},
+	OP_UNARY:                {1},
+	OP_CHECK_LOGICAL:        {1, 1},
+	OP_JUMP:                 {2},
+	OP_JUMP_IF_FALSE:        {2},
+	OP_JUMP_IF_FALSE_OR_POP: {2},
+	OP_JUMP_IF_TRUE_OR_POP:  {2},
+	OP_LOOP:                 {2},
+	OP_UNWIND:               {1},
+	OP_CALL:                 {1, 2},
+	OP_CLOSURE:              {2},
+	OP_ARRAY:                {2},
+	OP_MAP:                  {2},
+	OP_SET_INDEX:            {1},
+	OP_IMPORT:               {2},
+	OP_GET_MEMBER:           {2},
+}
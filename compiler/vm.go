@@ -0,0 +1,510 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+// Closure is a Proto closed over the scope it was created in. The VM hands
+// closures out as core.Function values with the Closure in Code, so they
+// can be stored, printed and passed to builtins like any other function.
+type Closure struct {
+	proto *Proto
+	scope *scope
+	vm    *VM
+}
+
+// scope holds the variables of one materialized scope. Slots are resolved
+// to a depth and an index at compile time, so no names are looked up.
+type scope struct {
+	slots  []any
+	parent *scope
+	info   *ScopeInfo
+}
+
+// undefined marks a slot whose declaration has not run yet.
+type undefined struct{}
+
+type loopState struct {
+	stackTop int
+	scope    *scope
+}
+
+type frame struct {
+	closure *Closure
+	ip      int
+	base    int
+	scope   *scope
+	loops   []loopState
+	// called is set for frames of calls in the program, which count
+	// against the call depth until they return.
+	called bool
+}
+
+// VM executes compiled Protos. Globals live in a core.Environment so that
+// builtins and REPL state are shared with the tree-walking interpreter.
+// Loop iterations, calls and array sizes are counted with the globals, so
+// running the VM inside Interpreter.ExecuteContext applies its context
+// and Limits.
+type VM struct {
+	globals *core.Environment
+	stack   []any
+	frames  []*frame
+}
+
+func NewVM(globals *core.Environment) *VM {
+	return &VM{
+		globals: globals,
+	}
+}
+
+// Run executes a Proto returned by Compile and returns the value of the
+// program's last expression.
+func (vm *VM) Run(script *Proto) (any, error) {
+	closure := &Closure{proto: script, vm: vm}
+	return vm.invoke(closure, nil)
+}
+
+func (vm *VM) function(closure *Closure) core.Function {
+	return core.Function{
+		Arity: closure.proto.Arity,
+		Code:  closure,
+		Call: func(_ *core.Environment, args []any) (any, error) {
+			return closure.vm.invoke(closure, args)
+		},
+	}
+}
+
+// invoke runs closure to completion on top of whatever the VM is currently
+// executing. It is used for the script itself and whenever Go code, such as
+// a builtin, calls back into a compiled function.
+func (vm *VM) invoke(closure *Closure, args []any) (any, error) {
+	base := len(vm.stack)
+	depth := len(vm.frames)
+
+	vm.push(nil)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+	vm.pushFrame(closure, base, len(args))
+
+	result, err := vm.run(depth)
+	if err != nil {
+		for _, f := range vm.frames[depth:] {
+			if f.called {
+				vm.globals.ExitCall()
+			}
+		}
+		vm.stack = vm.stack[:base]
+		vm.frames = vm.frames[:depth]
+		return nil, err
+	}
+	return result, nil
+}
+
+func (vm *VM) push(value any) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() any {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) any {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// pushFrame starts a call of closure whose callee sits at base, followed by
+// argc arguments. Arguments move from the stack into the parameter scope.
+func (vm *VM) pushFrame(closure *Closure, base int, argc int) {
+	proto := closure.proto
+	f := &frame{
+		closure: closure,
+		base:    base,
+		scope:   closure.scope,
+	}
+
+	if proto.Scope != nil {
+		slots := make([]any, len(proto.Scope.Names))
+		copy(slots, vm.stack[base+1:base+1+argc])
+		for i := argc; i < len(slots); i++ {
+			slots[i] = undefined{}
+		}
+		f.scope = &scope{
+			slots:  slots,
+			parent: closure.scope,
+			info:   proto.Scope,
+		}
+	}
+
+	vm.stack = vm.stack[:base+1]
+	vm.frames = append(vm.frames, f)
+}
+
+func (f *frame) readByte() byte {
+	b := f.closure.proto.Chunk.Code[f.ip]
+	f.ip++
+	return b
+}
+
+func (f *frame) readU16() int {
+	value := f.closure.proto.Chunk.readU16(f.ip)
+	f.ip += 2
+	return value
+}
+
+func (f *frame) constant() any {
+	return f.closure.proto.Chunk.Constants[f.readU16()]
+}
+
+func (f *frame) ancestor(depth int) *scope {
+	s := f.scope
+	for i := 0; i < depth; i++ {
+		s = s.parent
+	}
+	return s
+}
+
+func (vm *VM) errorAt(f *frame, offset int, format string, args ...any) error {
+	return &core.RuntimeError{
+		Span:    f.closure.proto.Chunk.Spans[offset],
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// wrapError locates an error raised by a builtin or a core helper at the
+// instruction that caused it, like the tree-walker does.
+func (vm *VM) wrapError(f *frame, offset int, err error) error {
+	if _, ok := core.ErrorSpan(err); ok || core.IsExit(err) {
+		return err
+	}
+	return vm.errorAt(f, offset, "%s", err.Error())
+}
+
+func logicalSymbol(op core.TokenType) string {
+	if op == core.AMP_AMP {
+		return "&&"
+	}
+	return "||"
+}
+
+// run executes instructions until the frame at index stopDepth returns and
+// yields its return value.
+func (vm *VM) run(stopDepth int) (any, error) {
+	f := vm.frames[len(vm.frames)-1]
+	for {
+		offset := f.ip
+		op := Opcode(f.readByte())
+
+		switch op {
+		case OP_CONSTANT:
+			vm.push(f.constant())
+		case OP_NIL:
+			vm.push(nil)
+		case OP_TRUE:
+			vm.push(true)
+		case OP_FALSE:
+			vm.push(false)
+		case OP_POP:
+			vm.pop()
+		case OP_SWAP:
+			top := len(vm.stack) - 1
+			vm.stack[top], vm.stack[top-1] = vm.stack[top-1], vm.stack[top]
+		case OP_DUP2:
+			vm.push(vm.peek(1))
+			vm.push(vm.peek(1))
+
+		case OP_GET_GLOBAL:
+			name := f.constant().(string)
+			value, err := vm.globals.Get(name)
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+			vm.push(value)
+		case OP_SET_GLOBAL:
+			name := f.constant().(string)
+			err := vm.globals.Set(name, vm.peek(0))
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+		case OP_DEFINE_GLOBAL:
+			name := f.constant().(string)
+			vm.globals.Declare(name, vm.peek(0))
+		case OP_GET_LOCAL:
+			s := f.ancestor(int(f.readByte()))
+			slot := f.readByte()
+			value := s.slots[slot]
+			if _, ok := value.(undefined); ok {
+				return nil, vm.errorAt(f, offset, "Variable %s not found", s.info.Names[slot])
+			}
+			vm.push(value)
+		case OP_SET_LOCAL:
+			s := f.ancestor(int(f.readByte()))
+			s.slots[f.readByte()] = vm.peek(0)
+
+		case OP_ENTER_SCOPE:
+			info := f.constant().(*ScopeInfo)
+			slots := make([]any, len(info.Names))
+			for i := range slots {
+				slots[i] = undefined{}
+			}
+			f.scope = &scope{slots: slots, parent: f.scope, info: info}
+		case OP_EXIT_SCOPE:
+			f.scope = f.scope.parent
+
+		case OP_BINARY:
+			operator := core.TokenType(f.readByte())
+			r := vm.pop()
+			l := vm.pop()
+			if result, ok := fastBinary(operator, l, r); ok {
+				vm.push(result)
+				continue
+			}
+			result, err := core.BinaryOp(operator, l, r)
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+			vm.push(result)
+		case OP_UNARY:
+			operator := core.TokenType(f.readByte())
+			result, err := core.UnaryOp(operator, vm.pop())
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+			vm.push(result)
+		case OP_CHECK_LOGICAL:
+			operator := core.TokenType(f.readByte())
+			side := "left"
+			if f.readByte() == 1 {
+				side = "right"
+			}
+			if _, ok := vm.peek(0).(bool); !ok {
+				return nil, vm.errorAt(f, offset, "Expected Bool as %s operand of %v, got %s", side, logicalSymbol(operator), core.TypeName(vm.peek(0)))
+			}
+
+		case OP_JUMP:
+			distance := f.readU16()
+			f.ip += distance
+		case OP_JUMP_IF_FALSE:
+			distance := f.readU16()
+			value := vm.pop()
+			condition, ok := value.(bool)
+			if !ok {
+				return nil, vm.errorAt(f, offset, "Expected Bool, got %s", core.TypeName(value))
+			}
+			if !condition {
+				f.ip += distance
+			}
+		case OP_JUMP_IF_FALSE_OR_POP:
+			distance := f.readU16()
+			if !vm.peek(0).(bool) {
+				f.ip += distance
+			} else {
+				vm.pop()
+			}
+		case OP_JUMP_IF_TRUE_OR_POP:
+			distance := f.readU16()
+			if vm.peek(0).(bool) {
+				f.ip += distance
+			} else {
+				vm.pop()
+			}
+		case OP_LOOP:
+			distance := f.readU16()
+			err := vm.globals.Step(f.closure.proto.Chunk.Spans[offset])
+			if err != nil {
+				return nil, err
+			}
+			f.ip -= distance
+
+		case OP_LOOP_ENTER:
+			f.loops = append(f.loops, loopState{stackTop: len(vm.stack), scope: f.scope})
+		case OP_LOOP_EXIT:
+			f.loops = f.loops[:len(f.loops)-1]
+		case OP_UNWIND:
+			f.loops = f.loops[:len(f.loops)-int(f.readByte())]
+			target := f.loops[len(f.loops)-1]
+			vm.stack = vm.stack[:target.stackTop]
+			f.scope = target.scope
+
+		case OP_CALL:
+			argc := int(f.readByte())
+			name := f.constant()
+			err := vm.call(f, offset, argc, name)
+			if err != nil {
+				return nil, err
+			}
+			f = vm.frames[len(vm.frames)-1]
+		case OP_CLOSURE:
+			proto := f.constant().(*Proto)
+			vm.push(vm.function(&Closure{proto: proto, scope: f.scope, vm: vm}))
+		case OP_RETURN:
+			result := vm.pop()
+			vm.stack = vm.stack[:f.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if f.called {
+				vm.globals.ExitCall()
+			}
+			if len(vm.frames) == stopDepth {
+				return result, nil
+			}
+			vm.push(result)
+			f = vm.frames[len(vm.frames)-1]
+
+		case OP_ARRAY:
+			count := f.readU16()
+			err := vm.globals.CheckArraySize(f.closure.proto.Chunk.Spans[offset], int64(count))
+			if err != nil {
+				return nil, err
+			}
+			elements := make([]any, count)
+			copy(elements, vm.stack[len(vm.stack)-count:])
+			vm.stack = vm.stack[:len(vm.stack)-count]
+			vm.push(core.NewArray(elements))
+		case OP_MAP:
+			count := f.readU16()
+			entries := vm.stack[len(vm.stack)-2*count:]
+			m := core.NewMap()
+			for i := 0; i < count; i++ {
+				err := m.Set(entries[2*i], entries[2*i+1])
+				if err != nil {
+					return nil, vm.wrapError(f, offset, err)
+				}
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*count]
+			vm.push(m)
+		case OP_INDEX:
+			key := vm.pop()
+			container := vm.pop()
+			value, err := core.IndexValue(container, key)
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+			vm.push(value)
+		case OP_SET_INDEX:
+			assignment := core.TokenType(f.readByte())
+			value := vm.pop()
+			key := vm.pop()
+			container := vm.pop()
+			if op, ok := core.CompoundOperator(assignment); ok {
+				current, err := core.IndexValue(container, key)
+				if err != nil {
+					return nil, vm.wrapError(f, offset, err)
+				}
+				value, err = core.BinaryOp(op, current, value)
+				if err != nil {
+					return nil, vm.wrapError(f, offset, err)
+				}
+			}
+			// Assigning one past the end appends to the array.
+			if array, ok := container.(*core.Array); ok {
+				if i, ok := key.(int64); ok && i == int64(array.Len()) {
+					err := vm.globals.CheckArraySize(f.closure.proto.Chunk.Spans[offset], i+1)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			err := core.SetIndex(container, key, value)
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+			vm.push(value)
+
+		case OP_IMPORT:
+			path := f.constant().(string)
+			module, err := vm.globals.Import(path, f.closure.proto.Chunk.Spans[offset].File)
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+			vm.push(module)
+		case OP_GET_MEMBER:
+			name := f.constant().(string)
+			value, err := core.Member(vm.pop(), name)
+			if err != nil {
+				return nil, vm.wrapError(f, offset, err)
+			}
+			vm.push(value)
+
+		default:
+			return nil, vm.errorAt(f, offset, "Unknown opcode %v", op)
+		}
+	}
+}
+
+// call dispatches OP_CALL. Compiled closures get a new frame on this VM,
+// every other function, builtins included, is called through Go.
+func (vm *VM) call(f *frame, offset int, argc int, name any) error {
+	base := len(vm.stack) - argc - 1
+	function, ok := vm.stack[base].(core.Function)
+	if !ok {
+		return vm.errorAt(f, offset, "Invalid Function %v", name)
+	}
+	if !function.Accepts(argc) {
+		return vm.errorAt(f, offset, "Arity does not match at Function %v", name)
+	}
+
+	span := f.closure.proto.Chunk.Spans[offset]
+	err := vm.globals.EnterCall(span)
+	if err != nil {
+		return err
+	}
+	if closure, ok := function.Code.(*Closure); ok && closure.vm == vm {
+		vm.pushFrame(closure, base, argc)
+		vm.frames[len(vm.frames)-1].called = true
+		return nil
+	}
+
+	args := make([]any, argc)
+	copy(args, vm.stack[base+1:])
+	vm.stack = vm.stack[:base]
+	result, err := function.Call(vm.globals, args)
+	vm.globals.ExitCall()
+	if err != nil {
+		return vm.wrapError(f, offset, err)
+	}
+	vm.push(result)
+	return nil
+}
+
+// fastBinary handles the common Int and Float cases without going through
+// core.BinaryOp. It reports false whenever the general path is needed.
+func fastBinary(operator core.TokenType, l any, r any) (any, bool) {
+	a, ok := l.(int64)
+	if !ok {
+		return nil, false
+	}
+	b, ok := r.(int64)
+	if !ok {
+		return nil, false
+	}
+
+	switch operator {
+	case core.PLUS:
+		return a + b, true
+	case core.MINUS:
+		return a - b, true
+	case core.STAR:
+		return a * b, true
+	case core.LESS:
+		return a < b, true
+	case core.LESS_EQ:
+		return a <= b, true
+	case core.GREATER:
+		return a > b, true
+	case core.GREATER_EQ:
+		return a >= b, true
+	case core.EQUAL_EQ:
+		return a == b, true
+	case core.BANG_EQ:
+		return a != b, true
+	case core.PERCENT:
+		if b != 0 {
+			return a % b, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,312 @@
+package compiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+// stdin is what the programs read from io.
+const stdin = "the quick brown\nfox\n\njumps over\n"
+
+// run runs src on a fresh Interpreter with the tree-walker, or compiled on
+// the VM, and returns what it printed and the error it ended with.
+func run(t *testing.T, file string, src string, limits core.Limits, vm bool) (string, string) {
+	t.Helper()
+
+	var out bytes.Buffer
+	interp := core.NewInterpreter()
+	interp.Stdout = &out
+	interp.Stderr = &out
+	interp.Stdin = strings.NewReader(stdin)
+	interp.Limits = limits
+
+	program, err := interp.Parse(file, src)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if vm {
+		proto, err := Compile(program)
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		machine := NewVM(interp.Globals())
+		_, err = interp.ExecuteContext(context.Background(), func() (any, error) {
+			return machine.Run(proto)
+		})
+		return out.String(), errorText(err)
+	}
+	_, err = interp.Run(program)
+	return out.String(), errorText(err)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// crossCheck fails unless both backends print the same and end with the
+// same error.
+func crossCheck(t *testing.T, file string, src string, limits core.Limits) (string, string) {
+	t.Helper()
+
+	treeOut, treeErr := run(t, file, src, limits, false)
+	vmOut, vmErr := run(t, file, src, limits, true)
+	if treeOut != vmOut {
+		t.Errorf("Output differs\ntree-walker:\n%s\nvm:\n%s", treeOut, vmOut)
+	}
+	if treeErr != vmErr {
+		t.Errorf("Error differs\ntree-walker: %s\nvm:          %s", treeErr, vmErr)
+	}
+	return treeOut, treeErr
+}
+
+func TestExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.lagn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No examples found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, runErr := crossCheck(t, file, string(content), core.Limits{MaxCallDepth: core.DefaultMaxCallDepth})
+			if runErr != "" {
+				t.Errorf("Example failed: %s", runErr)
+			}
+		})
+	}
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		out    string
+		err    string
+		limits core.Limits
+	}{
+		{
+			name: "arithmetic",
+			src:  `print(1 + 2 * 3) print(7 / 2) print(7 % 3) print(1 - 2.5) print(2 * 1.5) print(5.5 % 2)`,
+			out:  "7\n3\n1\n-1.5\n3\n1.5\n",
+		},
+		{
+			name: "comparison",
+			src:  `print(1 < 2.5) print("a" < "b") print(2 == 2.0) print("x" != "y")`,
+			out:  "true\ntrue\ntrue\ntrue\n",
+		},
+		{
+			name: "string concatenation",
+			src:  `print("n = " + 1) print("#" + 2.5)`,
+			out:  "n = 1\n#2.5\n",
+		},
+		{
+			name: "division by zero",
+			src:  `print(1) x := 1 / 0 print(2)`,
+			out:  "1\n",
+			err:  "[ERROR] Integer division by zero at Line 1, Column 17",
+		},
+		{
+			name: "unsupported operands",
+			src:  `true + 1`,
+			err:  "[ERROR] Unsupported operand types for +: Bool and Int at Line 1, Column 6",
+		},
+		{
+			name: "unary type error",
+			src:  `!1`,
+			err:  "[ERROR] Expected Bool, got Int at Line 1, Column 1",
+		},
+		{
+			name: "condition type error",
+			src:  `if (1) print("yes")`,
+			err:  "[ERROR] Expected Bool, got Int at Line 1, Column 1",
+		},
+		{
+			name: "short circuit",
+			src:  `fn loud(v) { print(v) return v } print(loud(false) && loud(true)) print(loud(true) || loud(false))`,
+			out:  "false\nfalse\ntrue\ntrue\n",
+		},
+		{
+			name: "closures",
+			src: `fn counter() { n := 0 return fn() { n += 1 return n } }
+a := counter() b := counter() a() a() print(a()) print(b())`,
+			out: "3\n1\n",
+		},
+		{
+			name: "early return",
+			src:  `fn first(arr) { for (i := 0; i < #arr; i += 1) { if (arr[i] > 2) return arr[i] } return nil } print(first([1, 5, 3])) print(first([]))`,
+			out:  "5\n<nil>\n",
+		},
+		{
+			name: "labeled break and continue",
+			src: `outer: for (i := 0; i < 3; i += 1) {
+  for (j := 0; j < 3; j += 1) {
+    if (j == 1) continue outer
+    if (i == 2) break outer
+    print(i + "," + j)
+  }
+}`,
+			out: "0,0\n1,0\n",
+		},
+		{
+			name: "index assignment",
+			src:  `a := [1, 2] a[0] += 10 a[2] = 3 a[#a] = 4 print(a) m := {"k": 1} m["k"] *= 5 m["j"] = 2 print(m["k"] + m["j"])`,
+			out:  "[11, 2, 3, 4]\n7\n",
+		},
+		{
+			name: "index past the end",
+			src:  `a := [] a[1000000000] = 1`,
+			err:  "[ERROR] Index 1000000000 out of range for Array of length 0 at Line 1, Column 23",
+		},
+		{
+			name: "index type error",
+			src:  `[1, 2]["x"]`,
+			err:  "[ERROR] Expected Int as index, got String at Line 1, Column 7",
+		},
+		{
+			name: "strings",
+			src:  `s := "héllo" print(#s) print(s[1]) print(#"")`,
+			out:  "5\né\n0\n",
+		},
+		{
+			name: "length type error",
+			src:  `#5`,
+			err:  "[ERROR] Expected Array, Map or String, got Int at Line 1, Column 1",
+		},
+		{
+			name: "higher order",
+			src:  `print(map([1, 2, 3], fn(x) x * x)) print(filter([1, 2, 3, 4], fn(x) x % 2 == 0)) print(reduce([1, 2, 3], fn(a, b) a + b, 0))`,
+			out:  "[1, 4, 9]\n[2, 4]\n6\n",
+		},
+		{
+			name: "callback error",
+			src:  `map([1, 0], fn(x) 1 / x)`,
+			err:  "[ERROR] Integer division by zero at Line 1, Column 21",
+		},
+		{
+			name: "native modules",
+			src:  `import "math" import "strings" print(math.sqrt(16)) print(strings.upper("abc"))`,
+			out:  "4\nABC\n",
+		},
+		{
+			name: "call a non-function",
+			src:  `x := 1 x()`,
+			err:  "[ERROR] Invalid Function x at Line 1, Column 9",
+		},
+		{
+			name: "arity",
+			src:  `fn f(a) a f(1, 2)`,
+			err:  "[ERROR] Arity does not match at Function f at Line 1, Column 12",
+		},
+		{
+			name:   "step limit",
+			src:    `while (true) {}`,
+			err:    "[ERROR] Step limit of 100 exceeded at Line 1, Column 1",
+			limits: core.Limits{MaxSteps: 100},
+		},
+		{
+			name:   "call depth",
+			src:    `fn f(n) f(n + 1) f(0)`,
+			err:    "[ERROR] Maximum call depth of 50 exceeded at Line 1, Column 10",
+			limits: core.Limits{MaxCallDepth: 50},
+		},
+		{
+			name:   "call depth through a builtin",
+			src:    `fn f(n) { return map([1], fn(x) f(n + 1)) } f(0)`,
+			err:    "[ERROR] Maximum call depth of 50 exceeded at Line 1, Column 21",
+			limits: core.Limits{MaxCallDepth: 50},
+		},
+		{
+			name:   "array literal size",
+			src:    `[1, 2, 3]`,
+			err:    "[ERROR] Array of size 3 exceeds the limit of 2 at Line 1, Column 1",
+			limits: core.Limits{MaxArraySize: 2},
+		},
+		{
+			name:   "array append size",
+			src:    `a := [1, 2] a[2] = 3`,
+			err:    "[ERROR] Array of size 3 exceeds the limit of 2 at Line 1, Column 18",
+			limits: core.Limits{MaxArraySize: 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := crossCheck(t, "", test.src, test.limits)
+			if out != test.out {
+				t.Errorf("Output\n%s\nwant\n%s", out, test.out)
+			}
+			if err != test.err {
+				t.Errorf("Error %q, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	interp := core.NewInterpreter()
+	program, err := interp.Parse("", `while (true) {}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto, err := Compile(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine := NewVM(interp.Globals())
+	_, err = interp.ExecuteContext(ctx, func() (any, error) {
+		return machine.Run(proto)
+	})
+
+	var canceled *core.CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("Got %v, want a CanceledError", err)
+	}
+}
+
+func TestCompileErrorLocation(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("x := 0\n")
+	for i := 0; i < 70000; i++ {
+		fmt.Fprintf(&src, "x = %d\n", i+1000)
+	}
+
+	interp := core.NewInterpreter()
+	program, err := interp.Parse("", src.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Compile(program)
+
+	var compileErr *CompileError
+	if !errors.As(err, &compileErr) {
+		t.Fatalf("Got %v, want a CompileError", err)
+	}
+	span, ok := core.ErrorSpan(err)
+	if !ok || span.Line != 32769 {
+		t.Errorf("ErrorSpan is %v, %v, want line 32769", span, ok)
+	}
+	if !strings.Contains(core.FormatError(err, src.String()), "^") {
+		t.Errorf("FormatError does not mark the span:\n%s", core.FormatError(err, src.String()))
+	}
+}
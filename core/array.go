@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Array is the interpreter's list value. Like Map it is handled by pointer,
+// so growing an array through one binding is visible through every other.
+type Array struct {
+	elements []any
+}
+
+func NewArray(elements []any) *Array {
+	return &Array{
+		elements: elements,
+	}
+}
+
+func (a *Array) Len() int {
+	return len(a.elements)
+}
+
+func (a *Array) Elements() []any {
+	return a.elements
+}
+
+func (a *Array) Get(i int64) (any, error) {
+	if i < 0 || i >= int64(len(a.elements)) {
+		return nil, fmt.Errorf("Index %d out of range for Array of length %d", i, len(a.elements))
+	}
+	return a.elements[i], nil
+}
+
+// Set stores value at index i. Assigning one past the end appends, any
+// other index must already exist.
+func (a *Array) Set(i int64, value any) error {
+	if i < 0 || i > int64(len(a.elements)) {
+		return fmt.Errorf("Index %d out of range for Array of length %d", i, len(a.elements))
+	}
+	if i == int64(len(a.elements)) {
+		a.elements = append(a.elements, value)
+		return nil
+	}
+	a.elements[i] = value
+	return nil
+}
+
+func (a *Array) String() string {
+	var b strings.Builder
+	b.WriteString("[")
+	for i, v := range a.elements {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(Inspect(v))
+	}
+	b.WriteString("]")
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package core
+
+import "testing"
+
+func TestArraySet(t *testing.T) {
+	a := NewArray([]any{int64(1)})
+	if err := a.Set(0, int64(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Set(1, int64(3)); err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != "[2, 3]" {
+		t.Errorf("Got %s, want [2, 3]", a)
+	}
+
+	for _, i := range []int64{-1, 3, 1 << 40} {
+		if err := a.Set(i, nil); err == nil {
+			t.Errorf("Set(%d) succeeded on an Array of length %d", i, a.Len())
+		}
+	}
+	if a.Len() != 2 {
+		t.Errorf("Failed sets changed the length to %d", a.Len())
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "overwrite and append",
+			src:  `a := [1, 2] a[0] = 5 a[#a] = 3 print(a)`,
+			out:  "[5, 2, 3]\n",
+		},
+		{
+			name: "compound operators",
+			src:  `a := [1, 2, 3, 4] a[0] += 10 a[1] -= 1 a[2] *= 3 a[3] /= 2 print(a)`,
+			out:  "[11, 1, 9, 2]\n",
+		},
+		{
+			name: "nested",
+			src:  `grid := [[0, 0], [0, 0]] grid[1][0] += 7 print(grid)`,
+			out:  "[[0, 0], [7, 0]]\n",
+		},
+		{
+			name: "shared through bindings",
+			src:  `a := [1] b := a b[1] = 2 print(a)`,
+			out:  "[1, 2]\n",
+		},
+		{
+			name: "value of the assignment",
+			src:  `a := [1] print(a[0] += 1)`,
+			out:  "2\n",
+		},
+		{
+			name: "past the end",
+			src:  `a := [] a[1] = 1`,
+			err:  "[ERROR] Index 1 out of range for Array of length 0 at Line 1, Column 14",
+		},
+		{
+			name: "negative",
+			src:  `a := [1] a[-1] = 1`,
+			err:  "[ERROR] Index -1 out of range for Array of length 1 at Line 1, Column 16",
+		},
+		{
+			name: "not a container",
+			src:  `a := 1 a[0] = 1`,
+			err:  "[ERROR] Expected Array or Map as assignment target, got Int at Line 1, Column 13",
+		},
+	})
+}
@@ -0,0 +1,458 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// arrayBuiltins returns the global functions working on Arrays.
+//
+// Functions taking a callback call it with Function.Invoke, so errors
+// raised inside the callback end the builtin and reach the program
+// unchanged. map, filter, sort, reverse, zip, concat and slice return new
+// Arrays, push, pop, insert and remove modify their argument.
+func arrayBuiltins() map[string]Function {
+	return map[string]Function{
+		"map": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, f, err := arrayAndFunction(args)
+				if err != nil {
+					return nil, err
+				}
+				result := make([]any, 0, array.Len())
+				for _, element := range array.elements {
+					value, err := f.Invoke(env, []any{element})
+					if err != nil {
+						return nil, err
+					}
+					result = append(result, value)
+				}
+				return NewArray(result), nil
+			},
+		},
+		"filter": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, f, err := arrayAndFunction(args)
+				if err != nil {
+					return nil, err
+				}
+				var result []any
+				for _, element := range array.elements {
+					keep, err := satisfies(env, f, element)
+					if err != nil {
+						return nil, err
+					}
+					if keep {
+						result = append(result, element)
+					}
+				}
+				return NewArray(result), nil
+			},
+		},
+		"reduce": {
+			Arity: 3,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, f, err := arrayAndFunction(args)
+				if err != nil {
+					return nil, err
+				}
+				accumulator := args[2]
+				for _, element := range array.elements {
+					accumulator, err = f.Invoke(env, []any{accumulator, element})
+					if err != nil {
+						return nil, err
+					}
+				}
+				return accumulator, nil
+			},
+		},
+		"each": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, f, err := arrayAndFunction(args)
+				if err != nil {
+					return nil, err
+				}
+				for _, element := range array.elements {
+					_, err := f.Invoke(env, []any{element})
+					if err != nil {
+						return nil, err
+					}
+				}
+				return nil, nil
+			},
+		},
+		"find": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, f, err := arrayAndFunction(args)
+				if err != nil {
+					return nil, err
+				}
+				for _, element := range array.elements {
+					found, err := satisfies(env, f, element)
+					if err != nil || found {
+						return element, err
+					}
+				}
+				return nil, nil
+			},
+		},
+		"any": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, f, err := arrayAndFunction(args)
+				if err != nil {
+					return nil, err
+				}
+				for _, element := range array.elements {
+					ok, err := satisfies(env, f, element)
+					if err != nil || ok {
+						return ok, err
+					}
+				}
+				return false, nil
+			},
+		},
+		"all": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, f, err := arrayAndFunction(args)
+				if err != nil {
+					return nil, err
+				}
+				for _, element := range array.elements {
+					ok, err := satisfies(env, f, element)
+					if err != nil || !ok {
+						return ok, err
+					}
+				}
+				return true, nil
+			},
+		},
+		"sort": {
+			Arity:    1,
+			Variadic: true,
+			Call: func(env *Environment, args []any) (any, error) {
+				if len(args) > 2 {
+					return nil, fmt.Errorf("sort takes an Array and an optional comparator, got %d arguments", len(args))
+				}
+				array, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				cmp := order
+				if len(args) == 2 {
+					f, err := functionArg(args[1])
+					if err != nil {
+						return nil, err
+					}
+					cmp = func(a any, b any) (int, error) {
+						return comparator(env, f, a, b)
+					}
+				}
+
+				// The sort cannot be stopped from inside the comparison, so
+				// after an error every remaining pair compares equal.
+				result := slices.Clone(array.elements)
+				var failed error
+				slices.SortStableFunc(result, func(a any, b any) int {
+					if failed != nil {
+						return 0
+					}
+					c, err := cmp(a, b)
+					if err != nil {
+						failed = err
+					}
+					return c
+				})
+				if failed != nil {
+					return nil, failed
+				}
+				return NewArray(result), nil
+			},
+		},
+		"reverse": {
+			Arity: 1,
+			Call: func(_ *Environment, args []any) (any, error) {
+				array, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				result := slices.Clone(array.elements)
+				slices.Reverse(result)
+				return NewArray(result), nil
+			},
+		},
+		"zip": {
+			Arity: 2,
+			Call: func(_ *Environment, args []any) (any, error) {
+				a, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				b, err := arrayArg(args[1])
+				if err != nil {
+					return nil, err
+				}
+				result := make([]any, min(a.Len(), b.Len()))
+				for i := range result {
+					result[i] = NewArray([]any{a.elements[i], b.elements[i]})
+				}
+				return NewArray(result), nil
+			},
+		},
+		"range": {
+			Arity:    1,
+			Variadic: true,
+			Call: func(env *Environment, args []any) (any, error) {
+				if len(args) > 3 {
+					return nil, fmt.Errorf("range takes at most 3 arguments, got %d", len(args))
+				}
+				bounds := make([]int64, len(args))
+				for i, arg := range args {
+					n, ok := arg.(int64)
+					if !ok {
+						return nil, fmt.Errorf("Expected Int, got %s", TypeName(arg))
+					}
+					bounds[i] = n
+				}
+
+				start, end, step := int64(0), bounds[0], int64(1)
+				if len(bounds) > 1 {
+					start, end = bounds[0], bounds[1]
+				}
+				if len(bounds) > 2 {
+					step = bounds[2]
+				}
+				if step == 0 {
+					return nil, fmt.Errorf("range step must not be 0")
+				}
+
+				// Unsigned arithmetic keeps the distance exact for any
+				// two Ints.
+				var size uint64
+				if step > 0 && start < end {
+					size = (uint64(end)-uint64(start)-1)/uint64(step) + 1
+				} else if step < 0 && start > end {
+					size = (uint64(start)-uint64(end)-1)/(-uint64(step)) + 1
+				}
+				if size > math.MaxInt32 {
+					return nil, fmt.Errorf("range of %d elements is too large", size)
+				}
+				err := env.CheckArraySize(env.callSite(), int64(size))
+				if err != nil {
+					return nil, err
+				}
+
+				result := make([]any, size)
+				for i := range result {
+					result[i] = start + int64(i)*step
+				}
+				return NewArray(result), nil
+			},
+		},
+		"push": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				err = env.CheckArraySize(env.callSite(), int64(array.Len())+1)
+				if err != nil {
+					return nil, err
+				}
+				array.elements = append(array.elements, args[1])
+				return array, nil
+			},
+		},
+		"pop": {
+			Arity: 1,
+			Call: func(_ *Environment, args []any) (any, error) {
+				array, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				if array.Len() == 0 {
+					return nil, fmt.Errorf("Cannot pop from an empty Array")
+				}
+				last := array.elements[array.Len()-1]
+				array.elements = array.elements[:array.Len()-1]
+				return last, nil
+			},
+		},
+		"insert": {
+			Arity: 3,
+			Call: func(env *Environment, args []any) (any, error) {
+				array, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				i, ok := args[1].(int64)
+				if !ok {
+					return nil, fmt.Errorf("Expected Int, got %s", TypeName(args[1]))
+				}
+				if i < 0 || i > int64(array.Len()) {
+					return nil, fmt.Errorf("Index %d out of range for Array of length %d", i, array.Len())
+				}
+				err = env.CheckArraySize(env.callSite(), int64(array.Len())+1)
+				if err != nil {
+					return nil, err
+				}
+				array.elements = slices.Insert(array.elements, int(i), args[2])
+				return array, nil
+			},
+		},
+		"remove": {
+			Arity: 2,
+			Call: func(_ *Environment, args []any) (any, error) {
+				array, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				i, ok := args[1].(int64)
+				if !ok {
+					return nil, fmt.Errorf("Expected Int, got %s", TypeName(args[1]))
+				}
+				removed, err := array.Get(i)
+				if err != nil {
+					return nil, err
+				}
+				array.elements = slices.Delete(array.elements, int(i), int(i)+1)
+				return removed, nil
+			},
+		},
+		"concat": {
+			Arity: 2,
+			Call: func(env *Environment, args []any) (any, error) {
+				a, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				b, err := arrayArg(args[1])
+				if err != nil {
+					return nil, err
+				}
+				err = env.CheckArraySize(env.callSite(), int64(a.Len()+b.Len()))
+				if err != nil {
+					return nil, err
+				}
+				return NewArray(append(slices.Clone(a.elements), b.elements...)), nil
+			},
+		},
+		"slice": {
+			Arity:    2,
+			Variadic: true,
+			Call: func(_ *Environment, args []any) (any, error) {
+				if len(args) > 3 {
+					return nil, fmt.Errorf("slice takes at most 3 arguments, got %d", len(args))
+				}
+				array, err := arrayArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				bounds := []int64{0, int64(array.Len())}
+				for i, arg := range args[1:] {
+					n, ok := arg.(int64)
+					if !ok {
+						return nil, fmt.Errorf("Expected Int, got %s", TypeName(arg))
+					}
+					bounds[i] = n
+				}
+
+				start, end := bounds[0], bounds[1]
+				if start < 0 || end < start || end > int64(array.Len()) {
+					return nil, fmt.Errorf("Slice [%d:%d] out of range for Array of length %d", start, end, array.Len())
+				}
+				return NewArray(slices.Clone(array.elements[start:end])), nil
+			},
+		},
+	}
+}
+
+func arrayArg(v any) (*Array, error) {
+	array, ok := v.(*Array)
+	if !ok {
+		return nil, fmt.Errorf("Expected Array, got %s", TypeName(v))
+	}
+	return array, nil
+}
+
+func functionArg(v any) (Function, error) {
+	f, ok := v.(Function)
+	if !ok {
+		return Function{}, fmt.Errorf("Expected Function, got %s", TypeName(v))
+	}
+	return f, nil
+}
+
+// arrayAndFunction reads the Array and callback arguments shared by most
+// of the builtins.
+func arrayAndFunction(args []any) (*Array, Function, error) {
+	array, err := arrayArg(args[0])
+	if err != nil {
+		return nil, Function{}, err
+	}
+	f, err := functionArg(args[1])
+	if err != nil {
+		return nil, Function{}, err
+	}
+	return array, f, nil
+}
+
+// satisfies calls the predicate f with element, which must return a Bool.
+func satisfies(env *Environment, f Function, element any) (bool, error) {
+	value, err := f.Invoke(env, []any{element})
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := value.(bool)
+	if !isBool {
+		return false, fmt.Errorf("Expected Bool from predicate, got %s", TypeName(value))
+	}
+	return ok, nil
+}
+
+// comparator calls the sort comparator f, which returns a negative number
+// when a comes first, a positive one when b does and 0 otherwise.
+func comparator(env *Environment, f Function, a any, b any) (int, error) {
+	value, err := f.Invoke(env, []any{a, b})
+	if err != nil {
+		return 0, err
+	}
+	switch n := value.(type) {
+	case int64:
+		return int(max(min(n, 1), -1)), nil
+	case float64:
+		if n < 0 {
+			return -1, nil
+		} else if n > 0 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("Expected Int or Float from comparator, got %s", TypeName(value))
+}
+
+// order compares a and b with the < and > operators.
+func order(a any, b any) (int, error) {
+	less, err := compare(LESS, a, b)
+	if err != nil {
+		return 0, err
+	}
+	if less.(bool) {
+		return -1, nil
+	}
+	greater, err := compare(GREATER, a, b)
+	if err != nil {
+		return 0, err
+	}
+	if greater.(bool) {
+		return 1, nil
+	}
+	return 0, nil
+}
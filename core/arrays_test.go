@@ -0,0 +1,95 @@
+package core
+
+import "testing"
+
+func TestArrayBuiltins(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "callbacks",
+			src: `print(map([1, 2, 3], fn(x) x * x))
+print(filter([1, 2, 3, 4], fn(x) x % 2 == 0))
+print(reduce([1, 2, 3], fn(a, b) a + b, 0))
+each(["a", "b"], fn(x) print(x))
+print(find([1, 5, 7], fn(x) x > 2))
+print(find([], fn(x) true))
+print(any([1, 2], fn(x) x > 1))
+print(all([1, 2], fn(x) x > 1))`,
+			out: "[1, 4, 9]\n[2, 4]\n6\na\nb\n5\n<nil>\ntrue\nfalse\n",
+		},
+		{
+			name: "sort",
+			src: `a := [3, 1.5, 2]
+print(sort(a))
+print(sort(a, fn(x, y) y - x))
+print(sort(["b", "a"]))
+print(a)`,
+			out: "[1.5, 2, 3]\n[3, 2, 1.5]\n[\"a\", \"b\"]\n[3, 1.5, 2]\n",
+		},
+		{
+			name: "new arrays",
+			src: `print(reverse([1, 2, 3]))
+print(zip([1, 2, 3], ["a", "b"]))
+print(range(3))
+print(range(5, 0, -2))
+print(concat([1], [2, 3]))
+print(slice([1, 2, 3, 4], 1))
+print(slice([1, 2, 3, 4], 1, 3))`,
+			out: "[3, 2, 1]\n[[1, \"a\"], [2, \"b\"]]\n[0, 1, 2]\n[5, 3, 1]\n[1, 2, 3]\n[2, 3, 4]\n[2, 3]\n",
+		},
+		{
+			name: "in place",
+			src: `a := [1, 2]
+push(a, 3)
+insert(a, 0, 0)
+print(pop(a))
+print(remove(a, 1))
+print(a)`,
+			out: "3\n1\n[0, 2]\n",
+		},
+		{
+			name: "callback error reaches the program",
+			src:  `map([1, 0], fn(x) 1 / x)`,
+			err:  "[ERROR] Integer division by zero at Line 1, Column 21",
+		},
+		{
+			name: "predicate result",
+			src:  `filter([1], fn(x) x)`,
+			err:  "[ERROR] Expected Bool from predicate, got Int at Line 1, Column 7",
+		},
+		{
+			name: "comparator result",
+			src:  `sort([1, 2], fn(a, b) "x")`,
+			err:  "[ERROR] Expected Int or Float from comparator, got String at Line 1, Column 5",
+		},
+		{
+			name: "incomparable elements",
+			src:  `sort([1, "a"])`,
+			err:  "[ERROR] Unsupported operand types for <: String and Int at Line 1, Column 5",
+		},
+		{
+			name: "not an array",
+			src:  `map(1, fn(x) x)`,
+			err:  "[ERROR] Expected Array, got Int at Line 1, Column 4",
+		},
+		{
+			name: "not a function",
+			src:  `map([1], 1)`,
+			err:  "[ERROR] Expected Function, got Int at Line 1, Column 4",
+		},
+		{
+			name: "pop from empty",
+			src:  `pop([])`,
+			err:  "[ERROR] Cannot pop from an empty Array at Line 1, Column 4",
+		},
+		{
+			name: "range step",
+			src:  `range(0, 5, 0)`,
+			err:  "[ERROR] range step must not be 0 at Line 1, Column 6",
+		},
+		{
+			name: "slice bounds",
+			src:  `slice([1, 2], 1, 3)`,
+			err:  "[ERROR] Slice [1:3] out of range for Array of length 2 at Line 1, Column 6",
+		},
+	})
+}
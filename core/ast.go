@@ -7,138 +7,333 @@ import (
 type Function struct {
 	fmt.Stringer
 	Arity int
-	Call  func(env Environment, args []any) (any, error)
+	// Variadic functions take Arity or more arguments, Call checks any
+	// upper bound itself.
+	Variadic bool
+	Call     func(env *Environment, args []any) (any, error)
+	// Code is private to the backend that created the Function, the
+	// bytecode VM keeps its closure here to call it without going through
+	// Call.
+	Code any
 }
 
 func (f Function) String() string {
+	if f.Variadic {
+		return fmt.Sprintf("f(%v...)", f.Arity)
+	}
 	return fmt.Sprintf("f(%v)", f.Arity)
 }
 
+// Accepts reports whether f can be called with argc arguments.
+func (f Function) Accepts(argc int) bool {
+	if f.Variadic {
+		return argc >= f.Arity
+	}
+	return argc == f.Arity
+}
+
+// Invoke calls f from Go code, such as a builtin calling back into the
+// program. The call counts against the limits like a call in the source
+// and limit errors are located at the call of the innermost Go function.
+func (f Function) Invoke(env *Environment, args []any) (any, error) {
+	if f.Call == nil {
+		return nil, fmt.Errorf("Invalid Function %v", f)
+	}
+	if !f.Accepts(len(args)) {
+		return nil, fmt.Errorf("Arity does not match at Function %v, got %d arguments", f, len(args))
+	}
+
+	err := env.EnterCall(env.callSite())
+	if err != nil {
+		return nil, err
+	}
+	defer env.ExitCall()
+	return f.Call(env, args)
+}
+
+var operatorSymbols = map[TokenType]string{
+	EQUAL:      "=",
+	COLON_EQ:   ":=",
+	PLUS_EQ:    "+=",
+	MINUS_EQ:   "-=",
+	STAR_EQ:    "*=",
+	SLASH_EQ:   "/=",
+	PERCENT_EQ: "%=",
+}
+
 type Expr interface {
 	fmt.Stringer
-	Interpret(environment Environment) (any, error)
+	Interpret(environment *Environment) (any, error)
 }
 
 type BinaryExpr struct {
 	Expr
-	rightExpr Expr
-	operator  Token
-	leftExpr  Expr
+	Right    Expr
+	Operator Token
+	Left     Expr
+}
+
+// LogicalExpr is a short-circuiting && or ||, the right operand is only
+// evaluated when the left one does not already decide the result.
+type LogicalExpr struct {
+	Expr
+	Right    Expr
+	Operator Token
+	Left     Expr
 }
 
 type UnaryExpr struct {
 	Expr
-	operator Token
-	expr     Expr
+	Operator Token
+	Operand  Expr
 }
 
 type GroupingExpr struct {
 	Expr
-	expr Expr
+	Inner Expr
 }
 
 type LiteralExpr struct {
 	Expr
-	value Token
+	Token Token
+	// Binding is filled in by the Resolver for identifiers that name a
+	// local variable.
+	Binding *Binding
 }
 
 type AssignExpr struct {
 	Expr
-	name     Token
-	expr     Expr
-	operator Token
+	Name     Token
+	Value    Expr
+	Operator Token
+	Binding  *Binding
 }
 
 type BlockExpr struct {
 	Expr
-	program []Expr
+	// Brace and RightBrace are left empty for blocks the parser creates
+	// itself, like the ones around loop bodies.
+	Brace      Token
+	RightBrace Token
+	Body       []Expr
+	// Slots is the number of variables declared directly in the block.
+	Slots int
 }
 
 type IfExpr struct {
 	Expr
-	condition  Expr
-	thenBranch Expr
-	elseBranch Expr
+	Keyword   Token
+	Condition Expr
+	Then      Expr
+	Else      Expr
 }
 
 type WhileExpr struct {
 	Expr
-	condition  Expr
-	loopBranch Expr
+	Keyword   Token
+	Label     Token
+	Condition Expr
+	Body      Expr
+	Increment Expr
 }
 
-
 type CallExpr struct {
 	Expr
-	f    Expr
-	args []Expr
+	Callee     Expr
+	Paren      Token
+	RightParen Token
+	Args       []Expr
 }
 
 type FnDeclExpr struct {
-  Expr
-  name Token
-	args []Token
-  program Expr
+	Expr
+	Keyword    Token
+	Name       Token
+	Paren      Token
+	Params     []Token
+	RightParen Token
+	Body       Expr
+	Binding    *Binding
+	// Slots is the size of the scope holding the parameters.
+	Slots int
+}
+
+type FnExpr struct {
+	Expr
+	Keyword    Token
+	Paren      Token
+	Params     []Token
+	RightParen Token
+	Body       Expr
+	Slots      int
 }
 
 type ArrayInitExpr struct {
-  Expr
-  values []Expr
+	Expr
+	Bracket      Token
+	RightBracket Token
+	Elements     []Expr
 }
 
 type IndexExpr struct {
-  Expr
-	value Expr
-	index Expr
+	Expr
+	Object  Expr
+	Bracket Token
+	Index   Expr
 }
 
-func (expr AssignExpr) String() string {
-	op := ""
-	if expr.operator.Type == COLON_EQ {
-		op = ":="
-	} else if expr.operator.Type == EQUAL {
-		op = "="
+type IndexAssignExpr struct {
+	Expr
+	Object   Expr
+	Index    Expr
+	Value    Expr
+	Operator Token
+}
+
+type MapInitExpr struct {
+	Expr
+	Brace      Token
+	RightBrace Token
+	Keys       []Expr
+	Values     []Expr
+}
+
+// ImportExpr loads a module and binds it to Name, or to a name derived
+// from Path when Name is omitted.
+type ImportExpr struct {
+	Expr
+	Keyword Token
+	Name    Token
+	Path    Token
+}
+
+// GetExpr reads the member Name of a module.
+type GetExpr struct {
+	Expr
+	Object Expr
+	Dot    Token
+	Name   Token
+}
+
+type ReturnExpr struct {
+	Expr
+	Keyword Token
+	Value   Expr
+}
+
+// returnSignal unwinds the interpreter from a ReturnExpr back to the
+// enclosing Function.Call. It travels through the error return path so
+// BlockExpr, WhileExpr and IfExpr stop evaluating immediately.
+type returnSignal struct {
+	value any
+}
+
+func (signal returnSignal) Error() string {
+	return "[ERROR] Cannot return from top-level code"
+}
+
+type BreakExpr struct {
+	Expr
+	Keyword Token
+	Label   Token
+}
+
+type ContinueExpr struct {
+	Expr
+	Keyword Token
+	Label   Token
+}
+
+// breakSignal and continueSignal unwind to the innermost WhileExpr, or to
+// the one carrying label when it is set.
+type breakSignal struct {
+	label string
+}
+
+func (signal breakSignal) Error() string {
+	return "[ERROR] Cannot use break outside of a loop"
+}
+
+type continueSignal struct {
+	label string
+}
+
+func (signal continueSignal) Error() string {
+	return "[ERROR] Cannot use continue outside of a loop"
+}
+
+// labelName returns the name of an optional label token, or "" when the
+// label was omitted.
+func labelName(label Token) string {
+	if name, ok := label.Value.(string); ok {
+		return name
 	}
-	return fmt.Sprintf("(%v %v %v)", expr.name.String(), op, expr.expr.String())
+	return ""
+}
+
+func (expr AssignExpr) String() string {
+	return fmt.Sprintf("(%v %v %v)", expr.Name.String(), operatorSymbols[expr.Operator.Type], expr.Value.String())
 }
 func (expr BinaryExpr) String() string {
-	return fmt.Sprintf("(%v %v %v)", expr.leftExpr.String(), expr.operator.String(), expr.rightExpr.String())
+	return fmt.Sprintf("(%v %v %v)", expr.Left.String(), expr.Operator.String(), expr.Right.String())
+}
+func (expr LogicalExpr) String() string {
+	return fmt.Sprintf("(%v %v %v)", expr.Left.String(), expr.Operator.String(), expr.Right.String())
 }
 func (expr UnaryExpr) String() string {
-	return fmt.Sprintf("(%v%v)", expr.operator.String(), expr.expr.String())
+	return fmt.Sprintf("(%v%v)", expr.Operator.String(), expr.Operand.String())
 }
 func (expr GroupingExpr) String() string {
-	return fmt.Sprintf("(%v)", expr.expr.String())
+	return fmt.Sprintf("(%v)", expr.Inner.String())
 }
 func (expr LiteralExpr) String() string {
-	return expr.value.String()
+	return expr.Token.String()
 }
 func (expr BlockExpr) String() string {
 	res := ""
-	for _, expr := range expr.program {
+	for _, expr := range expr.Body {
 		res += "\t" + expr.String()
 	}
 	return res
 }
 func (expr IfExpr) String() string {
-	res := fmt.Sprintf("if (%v) {\n", expr.condition.String())
-	res += expr.thenBranch.String()
-	if expr.elseBranch != nil {
+	res := fmt.Sprintf("if (%v) {\n", expr.Condition.String())
+	res += expr.Then.String()
+	if expr.Else != nil {
 		res += fmt.Sprintf("} else {\n")
-		res += expr.elseBranch.String()
+		res += expr.Else.String()
 	}
 	res += "}"
 	return res
 }
 func (expr WhileExpr) String() string {
-	res := fmt.Sprintf("while (%v) {\n", expr.condition.String())
-	res += expr.loopBranch.String()
+	res := ""
+	if name := labelName(expr.Label); name != "" {
+		res += name + ": "
+	}
+	res += fmt.Sprintf("while (%v) {\n", expr.Condition.String())
+	res += expr.Body.String()
+	if expr.Increment != nil {
+		res += "\t" + expr.Increment.String()
+	}
 	res += "}"
 	return res
 }
+func (expr BreakExpr) String() string {
+	if name := labelName(expr.Label); name != "" {
+		return "break " + name
+	}
+	return "break"
+}
+func (expr ContinueExpr) String() string {
+	if name := labelName(expr.Label); name != "" {
+		return "continue " + name
+	}
+	return "continue"
+}
 func (expr CallExpr) String() string {
-	res := fmt.Sprintf("%v(", expr.f.String())
-	for i, arg := range expr.args {
+	res := fmt.Sprintf("%v(", expr.Callee.String())
+	for i, arg := range expr.Args {
 		if i > 0 {
 			res += ", "
 		}
@@ -148,20 +343,32 @@ func (expr CallExpr) String() string {
 	return res
 }
 func (expr FnDeclExpr) String() string {
-	res := fmt.Sprintf("%v = (", expr.name.String())
-	for i, arg := range expr.args {
+	res := fmt.Sprintf("%v = (", expr.Name.String())
+	for i, arg := range expr.Params {
+		if i > 0 {
+			res += ", "
+		}
+		res += arg.String()
+	}
+	res += ") => \n"
+	res += expr.Body.String()
+	return res
+}
+func (expr FnExpr) String() string {
+	res := "("
+	for i, arg := range expr.Params {
 		if i > 0 {
 			res += ", "
 		}
 		res += arg.String()
 	}
 	res += ") => \n"
-  res += expr.program.String()
+	res += expr.Body.String()
 	return res
 }
 func (expr ArrayInitExpr) String() string {
 	res := "["
-	for i, val := range expr.values {
+	for i, val := range expr.Elements {
 		if i > 0 {
 			res += ", "
 		}
@@ -171,340 +378,519 @@ func (expr ArrayInitExpr) String() string {
 	return res
 }
 func (expr IndexExpr) String() string {
-  return fmt.Sprintf("%v[%v]", expr.value.String(), expr.index.String())
-}
-
-func (expr AssignExpr) Interpret(environment Environment) (any, error) {
-	data, err := expr.expr.Interpret(environment)
-  if err != nil {
-    return nil, err
-  }
-	if expr.operator.Type == COLON_EQ {
-		environment.declareVar(expr.name.String(), data)
-	} else if expr.operator.Type == EQUAL {
-		err := environment.setVar(expr.name.String(), data)
-		if err != nil {
-      return nil, err
+	return fmt.Sprintf("%v[%v]", expr.Object.String(), expr.Index.String())
+}
+func (expr IndexAssignExpr) String() string {
+	return fmt.Sprintf("(%v[%v] %v %v)", expr.Object.String(), expr.Index.String(), operatorSymbols[expr.Operator.Type], expr.Value.String())
+}
+func (expr MapInitExpr) String() string {
+	res := "{"
+	for i := range expr.Keys {
+		if i > 0 {
+			res += ", "
 		}
-	} else {
-		return nil, fmt.Errorf("Invalid assignment operator: %s", expr.operator.Type)
+		res += expr.Keys[i].String() + ": " + expr.Values[i].String()
 	}
-	return data, nil
+	res += "}"
+	return res
+}
+func (expr ImportExpr) String() string {
+	if expr.Name.Type == IDENTIFIER {
+		return fmt.Sprintf("import %v %v", expr.Name.String(), expr.Path.String())
+	}
+	return fmt.Sprintf("import %v", expr.Path.String())
+}
+func (expr GetExpr) String() string {
+	return fmt.Sprintf("%v.%v", expr.Object.String(), expr.Name.String())
+}
+func (expr ReturnExpr) String() string {
+	if expr.Value == nil {
+		return "return"
+	}
+	return fmt.Sprintf("return %v", expr.Value.String())
 }
 
-func (expr BinaryExpr) Interpret(environment Environment) (any, error) {
-	l, err := expr.leftExpr.Interpret(environment)
-  if err != nil {
-    return nil, err
-  }
-	r, err := expr.rightExpr.Interpret(environment)
-  if err != nil {
-    return nil, err
-  }
-
-	switch expr.operator.Type {
-  case PLUS:
-      switch left := l.(type) {
-      case int64:
-          switch right := r.(type) {
-          case int64:
-              return left + right, nil
-          case float64:
-              return float64(left) + right, nil
-          case string:
-              return fmt.Sprintf("%d%s", left, right), nil
-          default:
-              return nil, fmt.Errorf("Unsupported type for addition: Int + %T", right)
-          }
-      case float64:
-          switch right := r.(type) {
-          case int64:
-              return left + float64(right), nil
-          case float64:
-              return left + right, nil
-          case string:
-              return fmt.Sprintf("%f%s", left, right), nil
-          default:
-              return nil, fmt.Errorf("Unsupported type for addition: Float + %T", right)
-          }
-      case string:
-          switch right := r.(type) {
-          case int64:
-              return left + fmt.Sprintf("%d", right), nil
-          case float64:
-              return left + fmt.Sprintf("%f", right), nil
-          case string:
-              return left + right, nil
-          default:
-              return nil, fmt.Errorf("Unsupported type for addition: String + %T", right)
-          }
-      default:
-          return nil, fmt.Errorf("Unsupported type for addition: %T + %T", l, r)
-      }
-	case MINUS:
-		if leftInt, ok := l.(int64); ok {
-			if rightInt, ok := r.(int64); ok {
-				return leftInt - rightInt, nil
-			}
-		}
-		return l.(float64) - r.(float64), nil
-	case STAR:
-		if leftInt, ok := l.(int64); ok {
-			if rightInt, ok := r.(int64); ok {
-				return leftInt * rightInt, nil
-			}
-		}
-		return l.(float64) * r.(float64), nil
-	case SLASH:
-		if leftInt, ok := l.(int64); ok {
-			if rightInt, ok := r.(int64); ok {
-				return leftInt / rightInt, nil
-			}
-		}
-		return l.(float64) / r.(float64), nil
-	case PERCENT:
-		return l.(int64) % r.(int64), nil
-	case EQUAL_EQ:
-		return l == r, nil
-	case BANG_EQ:
-		return l != r, nil
-	case GREATER:
-		if leftInt, ok := l.(int64); ok {
-			if rightInt, ok := r.(int64); ok {
-				return leftInt > rightInt, nil
-			}
-		}
-		return l.(float64) > r.(float64), nil
-	case GREATER_EQ:
-		if leftInt, ok := l.(int64); ok {
-			if rightInt, ok := r.(int64); ok {
-				return leftInt >= rightInt, nil
-			}
-		}
-		return l.(float64) >= r.(float64), nil
-	case LESS:
-		if leftInt, ok := l.(int64); ok {
-			if rightInt, ok := r.(int64); ok {
-				return leftInt < rightInt, nil
-			}
+func (expr AssignExpr) Interpret(environment *Environment) (any, error) {
+	data, err := expr.Value.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+	if expr.Operator.Type == COLON_EQ {
+		environment.declare(expr.Name.String(), expr.Binding, data)
+	} else if expr.Operator.Type == EQUAL {
+		err := environment.assign(expr.Name.String(), expr.Binding, data)
+		if err != nil {
+			return nil, wrapRuntimeError(expr.Name, err)
 		}
-		return l.(float64) < r.(float64), nil
-	case LESS_EQ:
-		if leftInt, ok := l.(int64); ok {
-			if rightInt, ok := r.(int64); ok {
-				return leftInt <= rightInt, nil
-			}
+	} else if op, ok := CompoundOperator(expr.Operator.Type); ok {
+		current, err := environment.lookup(expr.Name.String(), expr.Binding)
+		if err != nil {
+			return nil, wrapRuntimeError(expr.Name, err)
 		}
-		return l.(float64) <= r.(float64), nil
-	case BAR:
-		return l.(int64) | r.(int64), nil
-	case BAR_BAR:
-		return l.(bool) || r.(bool), nil
-	case AMP:
-		return l.(int64) & r.(int64), nil
-	case AMP_AMP:
-		return l.(bool) && r.(bool), nil
-	default:
-		return nil, fmt.Errorf("Invalid Binary Operator %v", expr.operator)
-	}
-}
-
-func (expr UnaryExpr) Interpret(environment Environment) (any, error) {
-	res, err := expr.expr.Interpret(environment)
-  if err != nil {
-    return nil, err
-  }
-	switch expr.operator.Type {
-	case BANG:
-		if r, ok := res.(bool); ok {
-      return !r, nil
-    } 
-    return nil, fmt.Errorf("Expected Type bool, got %T", res)
-	case MINUS:
-		if r, ok := res.(int64); ok {
-			return -r, nil
+		data, err = BinaryOp(op, current, data)
+		if err != nil {
+			return nil, wrapRuntimeError(expr.Operator, err)
 		}
-		if r, ok := res.(float64); ok {
-			return -r, nil
+		err = environment.assign(expr.Name.String(), expr.Binding, data)
+		if err != nil {
+			return nil, wrapRuntimeError(expr.Name, err)
 		}
-    return nil, fmt.Errorf("Expected number, got %T", res)
-  case HASHTAG:
-    if r, ok := res.([]any); ok {
-      return int64(len(r)), nil
-    }
-    return nil, fmt.Errorf("Expected Array, got %T", res)
-	default:
-		return nil, fmt.Errorf("Invalid Unary Operator %v", expr.operator.Type)
+	} else {
+		return nil, newRuntimeError(expr.Operator, "Invalid assignment operator: %s", expr.Operator.Type)
+	}
+	return data, nil
+}
+
+func (expr BinaryExpr) Interpret(environment *Environment) (any, error) {
+	l, err := expr.Left.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+	r, err := expr.Right.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := BinaryOp(expr.Operator.Type, l, r)
+	if err != nil {
+		return nil, wrapRuntimeError(expr.Operator, err)
+	}
+	return res, nil
+}
+
+func (expr LogicalExpr) Interpret(environment *Environment) (any, error) {
+	l, err := expr.Left.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+	left, ok := l.(bool)
+	if !ok {
+		return nil, newRuntimeError(expr.Operator, "Expected Bool as left operand of %v, got %s", operatorSymbol(expr.Operator.Type), TypeName(l))
+	}
+
+	if expr.Operator.Type == AMP_AMP && !left {
+		return false, nil
+	}
+	if expr.Operator.Type == BAR_BAR && left {
+		return true, nil
+	}
+
+	r, err := expr.Right.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+	right, ok := r.(bool)
+	if !ok {
+		return nil, newRuntimeError(expr.Operator, "Expected Bool as right operand of %v, got %s", operatorSymbol(expr.Operator.Type), TypeName(r))
+	}
+	return right, nil
+}
+
+func (expr UnaryExpr) Interpret(environment *Environment) (any, error) {
+	res, err := expr.Operand.Interpret(environment)
+	if err != nil {
+		return nil, err
 	}
+
+	res, err = UnaryOp(expr.Operator.Type, res)
+	if err != nil {
+		return nil, wrapRuntimeError(expr.Operator, err)
+	}
+	return res, nil
 }
 
-func (expr GroupingExpr) Interpret(environment Environment) (any, error) {
-	return expr.expr.Interpret(environment)
+func (expr GroupingExpr) Interpret(environment *Environment) (any, error) {
+	return expr.Inner.Interpret(environment)
 }
 
-func (expr LiteralExpr) Interpret(environment Environment) (any, error) {
-	switch expr.value.Type {
+func (expr LiteralExpr) Interpret(environment *Environment) (any, error) {
+	switch expr.Token.Type {
 	case TRUE:
 		return true, nil
 	case FALSE:
 		return false, nil
 	case IDENTIFIER:
-		v, err := environment.findVar(expr.value.String())
+		v, err := environment.lookup(expr.Token.String(), expr.Binding)
 		if err != nil {
-      return nil, err
+			return nil, wrapRuntimeError(expr.Token, err)
 		}
 		return v, nil
 	default:
-		return expr.value.Value, nil
+		return expr.Token.Value, nil
 	}
 }
 
-func (expr BlockExpr) Interpret(environment Environment) (any, error) {
+func (expr BlockExpr) Interpret(environment *Environment) (any, error) {
 	var res any
-  var err error
-	environment.push(make(map[string]any))
-	for _, expr := range expr.program {
-		res, err = expr.Interpret(environment)
+	var err error
+	scope := newScope(environment, expr.Slots)
+	for _, expr := range expr.Body {
+		res, err = expr.Interpret(scope)
 
-    if err != nil {
-      return nil, err
-    }
+		if err != nil {
+			return nil, err
+		}
 	}
-	environment.pop()
 	return res, nil
 }
 
-func (expr IfExpr) Interpret(environment Environment) (any, error) {
-  condVal, err := expr.condition.Interpret(environment)
-  if err != nil {
-    return nil, err 
-  }
-  if _, ok := condVal.(bool); !ok {
-    return nil, fmt.Errorf("Expected Type bool, got %T", condVal)
-  }
+func (expr IfExpr) Interpret(environment *Environment) (any, error) {
+	condVal, err := expr.Condition.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := condVal.(bool); !ok {
+		return nil, newRuntimeError(expr.Keyword, "Expected Bool, got %s", TypeName(condVal))
+	}
 
 	if condVal.(bool) {
-		return expr.thenBranch.Interpret(environment)
-	} else if expr.elseBranch != nil {
-		return expr.elseBranch.Interpret(environment)
+		return expr.Then.Interpret(environment)
+	} else if expr.Else != nil {
+		return expr.Else.Interpret(environment)
 	}
 
 	return nil, nil
 }
 
-func (expr WhileExpr) Interpret(environment Environment) (any, error) {
-  condVal, err := expr.condition.Interpret(environment)
-  if err != nil {
-    return nil, err
-  }
-  if _, ok := condVal.(bool); !ok {
-    return nil, fmt.Errorf("Expected Type bool, got %T", condVal)
-  }
+func (expr WhileExpr) Interpret(environment *Environment) (any, error) {
+	condVal, err := expr.Condition.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := condVal.(bool); !ok {
+		return nil, newRuntimeError(expr.Keyword, "Expected Bool, got %s", TypeName(condVal))
+	}
 
+	label := labelName(expr.Label)
 	for condVal.(bool) {
-		_, err := expr.loopBranch.Interpret(environment)
-    if err != nil {
-      return nil, err
-    }
+		err := environment.Step(expr.Keyword.Span())
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = expr.Body.Interpret(environment)
+		switch signal := err.(type) {
+		case nil:
+		case breakSignal:
+			if signal.label == "" || signal.label == label {
+				return nil, nil
+			}
+			return nil, err
+		case continueSignal:
+			if signal.label != "" && signal.label != label {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
 
-    condVal, err = expr.condition.Interpret(environment)
-    if err != nil {
-      return nil, err
-    }
-    if _, ok := condVal.(bool); !ok {
-      return nil, fmt.Errorf("Expected Type bool, got %T", condVal)
-    }
+		if expr.Increment != nil {
+			_, err = expr.Increment.Interpret(environment)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		condVal, err = expr.Condition.Interpret(environment)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := condVal.(bool); !ok {
+			return nil, newRuntimeError(expr.Keyword, "Expected Bool, got %s", TypeName(condVal))
+		}
 	}
 
 	return nil, nil
 }
 
-func (expr CallExpr) Interpret(environment Environment) (any, error) {
-	f, err := environment.findVar(expr.f.String())
+func (expr CallExpr) Interpret(environment *Environment) (any, error) {
+	f, err := expr.Callee.Interpret(environment)
 	if err != nil {
 		return nil, err
 	}
 
 	function, ok := f.(Function)
 	if !ok {
-		return nil, fmt.Errorf("Invalid Function %v", function)
+		return nil, newRuntimeError(expr.Paren, "Invalid Function %v", expr.Callee.String())
 	}
 	args := []any{}
 
-	for _, arg := range expr.args {
-    a, err := arg.Interpret(environment)
-    if err != nil {
-      return nil, err
-    }
+	for _, arg := range expr.Args {
+		a, err := arg.Interpret(environment)
+		if err != nil {
+			return nil, err
+		}
 		args = append(args, a)
 	}
 
-  if len(args) != function.Arity {
-    return nil, fmt.Errorf("[ERROR] Arity does not match at Function %v", expr.f.String())
-  }
+	if !function.Accepts(len(args)) {
+		return nil, newRuntimeError(expr.Paren, "Arity does not match at Function %v", expr.Callee.String())
+	}
 
-	value, err := function.Call(environment, args)
+	err = environment.EnterCall(expr.Paren.Span())
 	if err != nil {
 		return nil, err
 	}
+	value, err := function.Call(environment, args)
+	environment.ExitCall()
+	if err != nil {
+		return nil, wrapRuntimeError(expr.Paren, err)
+	}
 
 	return value, nil
 }
 
-func (expr FnDeclExpr) Interpret(environment Environment) (any, error) {
-  f := Function {
-    Arity: len(expr.args),
-    Call: func(env Environment, args []any) (any, error) {
-      env.push(make(map[string]any))
+// makeFunction builds a Function whose body runs in a fresh scope chained to
+// the scope it was created in, not the caller's, so free variables are
+// resolved lexically. The parameters take the first slots of that scope.
+func makeFunction(params []Token, program Expr, slots int, environment *Environment) Function {
+	return Function{
+		Arity: len(params),
+		Call: func(_ *Environment, args []any) (any, error) {
+			scope := newScope(environment, slots)
+			copy(scope.slots, args)
+
+			result, err := program.Interpret(scope)
+			if signal, ok := err.(returnSignal); ok {
+				return signal.value, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		},
+	}
+}
+
+func (expr FnDeclExpr) Interpret(environment *Environment) (any, error) {
+	f := makeFunction(expr.Params, expr.Body, expr.Slots, environment)
+	environment.declare(expr.Name.Value.(string), expr.Binding, f)
+
+	return f, nil
+}
+
+func (expr FnExpr) Interpret(environment *Environment) (any, error) {
+	return makeFunction(expr.Params, expr.Body, expr.Slots, environment), nil
+}
+
+func (expr ArrayInitExpr) Interpret(environment *Environment) (any, error) {
+	err := environment.CheckArraySize(expr.Bracket.Span(), int64(len(expr.Elements)))
+	if err != nil {
+		return nil, err
+	}
+
+	var res []any
+	for _, val := range expr.Elements {
+		val, err := val.Interpret(environment)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, val)
+	}
+
+	return NewArray(res), nil
+}
+
+// IndexValue reads container[key] from an Array, a Map or a String.
+// Strings are indexed by rune and yield a String of that single rune.
+func IndexValue(container any, key any) (any, error) {
+	switch val := container.(type) {
+	case string:
+		i, ok := key.(int64)
+		if !ok {
+			return nil, fmt.Errorf("Expected Int as index, got %s", TypeName(key))
+		}
+		runes := []rune(val)
+		if i < 0 || i >= int64(len(runes)) {
+			return nil, fmt.Errorf("Index %d out of range for String of length %d", i, len(runes))
+		}
+		return string(runes[i]), nil
+	case *Array:
+		i, ok := key.(int64)
+		if !ok {
+			return nil, fmt.Errorf("Expected Int as index, got %s", TypeName(key))
+		}
+		return val.Get(i)
+	case *Map:
+		// Missing keys read as nil, use has() to tell them apart from a
+		// stored nil.
+		v, _, err := val.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("Expected Array, Map or String, got %s", TypeName(container))
+	}
+}
+
+// SetIndex stores container[key] = value into an Array or a Map.
+func SetIndex(container any, key any, value any) error {
+	switch val := container.(type) {
+	case *Array:
+		i, ok := key.(int64)
+		if !ok {
+			return fmt.Errorf("Expected Int as index, got %s", TypeName(key))
+		}
+		return val.Set(i, value)
+	case *Map:
+		return val.Set(key, value)
+	default:
+		return fmt.Errorf("Expected Array or Map as assignment target, got %s", TypeName(container))
+	}
+}
+
+func (expr IndexExpr) Interpret(environment *Environment) (any, error) {
+	valAny, err := expr.Object.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	iAny, err := expr.Index.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := IndexValue(valAny, iAny)
+	if err != nil {
+		return nil, wrapRuntimeError(expr.Bracket, err)
+	}
+	return res, nil
+}
+
+func (expr IndexAssignExpr) Interpret(environment *Environment) (any, error) {
+	obj, err := expr.Object.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := expr.Index.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := expr.Value.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if op, ok := CompoundOperator(expr.Operator.Type); ok {
+		current, err := IndexValue(obj, key)
+		if err != nil {
+			return nil, wrapRuntimeError(expr.Operator, err)
+		}
+		data, err = BinaryOp(op, current, data)
+		if err != nil {
+			return nil, wrapRuntimeError(expr.Operator, err)
+		}
+	} else if expr.Operator.Type != EQUAL {
+		return nil, newRuntimeError(expr.Operator, "Invalid assignment operator: %s", expr.Operator.Type)
+	}
+
+	// Assigning one past the end appends to the array.
+	if arr, ok := obj.(*Array); ok {
+		if i, ok := key.(int64); ok && i == int64(arr.Len()) {
+			err = environment.CheckArraySize(expr.Operator.Span(), i+1)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = SetIndex(obj, key, data)
+	if err != nil {
+		return nil, wrapRuntimeError(expr.Operator, err)
+	}
+	return data, nil
+}
+
+func (expr MapInitExpr) Interpret(environment *Environment) (any, error) {
+	res := NewMap()
+	for i := range expr.Keys {
+		key, err := expr.Keys[i].Interpret(environment)
+		if err != nil {
+			return nil, err
+		}
+		val, err := expr.Values[i].Interpret(environment)
+		if err != nil {
+			return nil, err
+		}
+		err = res.Set(key, val)
+		if err != nil {
+			return nil, wrapRuntimeError(expr.Brace, err)
+		}
+	}
+
+	return res, nil
+}
 
-      for i := range args {
-        env.declareVar(expr.args[i].Value.(string), args[i])
-      }
+// Alias returns the identifier the module is bound to, the explicit name
+// or the file name of the path without its extension.
+func (expr ImportExpr) Alias() Token {
+	if expr.Name.Type == IDENTIFIER {
+		return expr.Name
+	}
+	alias := expr.Path
+	alias.Type = IDENTIFIER
+	alias.Value = moduleName(expr.Path.Value.(string))
+	return alias
+}
 
-      result, err := expr.program.Interpret(env)
-      env.pop()
-      if err != nil {
-        return nil, err
-      }
+func (expr ImportExpr) Interpret(environment *Environment) (any, error) {
+	module, err := environment.Import(expr.Path.Value.(string), expr.Keyword.File)
+	if err != nil {
+		return nil, wrapRuntimeError(expr.Path, err)
+	}
 
-      return result, nil
-    },
-  }
+	environment.declareVar(expr.Alias().String(), module)
+	return module, nil
+}
 
-  environment.declareVar(expr.name.Value.(string), f)
+// Member reads object.name, object has to be a Module.
+func Member(object any, name string) (any, error) {
+	module, ok := object.(*Module)
+	if !ok {
+		return nil, fmt.Errorf("Expected Module before '.', got %s", TypeName(object))
+	}
 
-  return f, nil
+	value, ok := module.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("Module %s has no member %s", module.Name, name)
+	}
+	return value, nil
 }
 
-func (expr ArrayInitExpr) Interpret(environment Environment) (any, error) {
-  var res []any
-  for _, val := range expr.values {
-    val, err := val.Interpret(environment)
-    if err != nil {
-      return nil, err
-    }
-    res = append(res, val)
-  }
+func (expr GetExpr) Interpret(environment *Environment) (any, error) {
+	object, err := expr.Object.Interpret(environment)
+	if err != nil {
+		return nil, err
+	}
 
-  return res, nil
+	value, err := Member(object, expr.Name.String())
+	if err != nil {
+		return nil, wrapRuntimeError(expr.Name, err)
+	}
+	return value, nil
 }
 
-func (expr IndexExpr) Interpret(environment Environment) (any, error) {
-  valAny, err := expr.value.Interpret(environment)
-  if err != nil {
-    return nil, err
-  }
+func (expr ReturnExpr) Interpret(environment *Environment) (any, error) {
+	var value any
+	if expr.Value != nil {
+		var err error
+		value, err = expr.Value.Interpret(environment)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-  val, ok := valAny.([]any)
-  if !ok {
-    return nil, fmt.Errorf("Expcted type [] found %T", valAny)
-  }
+	return nil, returnSignal{value: value}
+}
 
-  iAny, err := expr.index.Interpret(environment)
-  i, ok := iAny.(int64)
-  if !ok {
-    return nil, fmt.Errorf("Expected type i64 found %T", iAny)
-  }
+func (expr BreakExpr) Interpret(environment *Environment) (any, error) {
+	return nil, breakSignal{label: labelName(expr.Label)}
+}
 
-  return val[i], nil
+func (expr ContinueExpr) Interpret(environment *Environment) (any, error) {
+	return nil, continueSignal{label: labelName(expr.Label)}
 }
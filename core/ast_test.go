@@ -0,0 +1,231 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// programTest is a lagn program with what it should print and the error,
+// if any, it should end with.
+type programTest struct {
+	name string
+	src  string
+	out  string
+	err  string
+}
+
+// run parses and runs src on a fresh Interpreter and returns what it
+// printed and the text of the error it ended with.
+func run(t *testing.T, interp *Interpreter, src string) (string, string) {
+	t.Helper()
+
+	var out bytes.Buffer
+	interp.Stdout = &out
+	interp.Stderr = &out
+	interp.Stdin = strings.NewReader("")
+
+	program, err := interp.Parse("", src)
+	if err == nil {
+		_, err = interp.Run(program)
+	}
+	if err != nil {
+		return out.String(), err.Error()
+	}
+	return out.String(), ""
+}
+
+func runPrograms(t *testing.T, tests []programTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := run(t, NewInterpreter(), test.src)
+			if out != test.out {
+				t.Errorf("Output\n%s\nwant\n%s", out, test.out)
+			}
+			if err != test.err {
+				t.Errorf("Error %q, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestReturn(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "early exit",
+			src:  `fn f(n) { if (n > 0) return "positive" print("fell through") return "other" } print(f(1)) print(f(0))`,
+			out:  "positive\nfell through\nother\n",
+		},
+		{
+			name: "from inside a loop",
+			src:  `fn find(arr, v) { for (i := 0; i < #arr; i += 1) { while (true) { if (arr[i] == v) return i break } } return -1 } print(find([4, 5, 6], 6)) print(find([], 1))`,
+			out:  "2\n-1\n",
+		},
+		{
+			name: "bare return",
+			src: `fn f() {
+  return
+}
+print(f())`,
+			out: "<nil>\n",
+		},
+		{
+			name: "bare return before else",
+			src:  `fn f(a) { if (a) return else return 1 } print(f(true)) print(f(false))`,
+			out:  "<nil>\n1\n",
+		},
+		{
+			name: "bare return before a closing parenthesis",
+			src:  `print(map([1], fn(x) return))`,
+			out:  "[<nil>]\n",
+		},
+		{
+			name: "bare return before a closing bracket",
+			src:  `g := [fn() return] print(g[0]())`,
+			out:  "<nil>\n",
+		},
+		{
+			name: "bare return before a comma",
+			src:  `fn second(a, b) b() print(second(fn() return, fn() return 2))`,
+			out:  "2\n",
+		},
+		{
+			name: "value on the next line",
+			src: `fn f() {
+  return
+  1
+}
+print(f())`,
+			out: "<nil>\n",
+		},
+		{
+			name: "top level",
+			src:  `return 1`,
+			err:  "[ERROR] Cannot return from top-level code at Line 1, Column 1",
+		},
+	})
+}
+
+func TestClosures(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "independent counters",
+			src:  `fn counter() { n := 0 return fn() { n += 1 return n } } a := counter() b := counter() a() a() print(a()) print(b())`,
+			out:  "3\n1\n",
+		},
+		{
+			name: "defining scope, not calling scope",
+			src:  `name := "global" fn show() print(name) fn shadow() { name := "local" show() } shadow()`,
+			out:  "global\n",
+		},
+		{
+			name: "sees later assignments",
+			src:  `x := 1 fn get() x x = 2 print(get())`,
+			out:  "2\n",
+		},
+		{
+			name: "each iteration",
+			src:  `fns := [] for (i := 0; i < 3; i += 1) { j := i fns[#fns] = fn() j } print(fns[0]() + fns[2]())`,
+			out:  "2\n",
+		},
+		{
+			name: "shared between closures",
+			src:  `fn pair() { n := 0 return [fn() { n += 1 return n }, fn() n] } p := pair() p[0]() p[0]() print(p[1]())`,
+			out:  "2\n",
+		},
+	})
+}
+
+func TestFnExpressions(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "immediately called",
+			src:  `print((fn(a, b) a * b)(6, 7))`,
+			out:  "42\n",
+		},
+		{
+			name: "returned and called",
+			src:  `fn compose(f, g) fn(x) f(g(x)) print(compose(fn(x) x * 2, fn(x) x + 1)(4))`,
+			out:  "10\n",
+		},
+		{
+			name: "indexed callee",
+			src:  `ops := [fn(x) x + 1, fn(x) x * x] print(ops[1](5)) print([fn(x) x][0](3))`,
+			out:  "25\n3\n",
+		},
+		{
+			name: "call a non-function",
+			src:  `x := 5 x()`,
+			err:  "[ERROR] Invalid Function x at Line 1, Column 9",
+		},
+		{
+			name: "arity",
+			src:  `f := fn(a, b) a f(1)`,
+			err:  "[ERROR] Arity does not match at Function f at Line 1, Column 18",
+		},
+		{
+			name: "duplicate parameter",
+			src:  `fn(x, x) x`,
+			err:  "[ERROR] Duplicate parameter x at Line 1, Column 7",
+		},
+	})
+}
+
+func TestBreakContinue(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "innermost loop",
+			src:  `for (i := 0; i < 10; i += 1) { if (i % 2 == 0) continue if (i > 7) break print(i) }`,
+			out:  "1\n3\n5\n7\n",
+		},
+		{
+			name: "while",
+			src:  `i := 0 while (true) { i += 1 if (i < 3) continue break } print(i)`,
+			out:  "3\n",
+		},
+		{
+			name: "labels",
+			src: `outer: for (i := 0; i < 3; i += 1) {
+  inner: while (true) {
+    if (i == 1) continue outer
+    if (i == 2) break outer
+    print(i)
+    break inner
+  }
+}`,
+			out: "0\n",
+		},
+		{
+			name: "identifier after continue",
+			src:  `s := 0 c := 0 while (c < 3) { c += 1 if (c == 2) continue s += 1 } print(s)`,
+			out:  "2\n",
+		},
+		{
+			name: "identifier after break",
+			src:  `x := 0 while (true) { break x = 1 } print(x)`,
+			out:  "0\n",
+		},
+		{
+			name: "not a label",
+			src:  `while (true) { break nosuch }`,
+			err:  "[ERROR] Undeclared variable nosuch at Line 1, Column 22",
+		},
+		{
+			name: "outside a loop",
+			src:  `break`,
+			err:  "[ERROR] Cannot use break outside of a loop at Line 1, Column 1",
+		},
+		{
+			name: "inside a function in a loop",
+			src:  `while (true) { fn f() continue }`,
+			err:  "[ERROR] Cannot use continue outside of a loop at Line 1, Column 23",
+		},
+		{
+			name: "label in use",
+			src:  `a: while (true) { a: while (true) break }`,
+			err:  "[ERROR] Label a is already in use at Line 1, Column 19",
+		},
+	})
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	functionType = reflect.TypeOf(Function{})
+)
+
+// ToValue converts a Go value into the value the interpreter works with:
+// integers become Int, floats Float, slices and arrays an Array, maps with
+// string or numeric keys a Map and functions a Function. Values that
+// already are interpreter values are returned unchanged.
+func ToValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil, int64, float64, string, bool, *Array, *Map, Function:
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		elements := make([]any, rv.Len())
+		for i := range elements {
+			element, err := ToValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return NewArray(elements), nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		m := NewMap()
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := ToValue(iter.Key().Interface())
+			if err != nil {
+				return nil, err
+			}
+			element, err := ToValue(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			err = m.Set(key, element)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case reflect.Func:
+		return wrapFunc("function", value)
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return ToValue(rv.Elem().Interface())
+	}
+
+	return nil, fmt.Errorf("Cannot convert %T to a lagn value", value)
+}
+
+// fromValue converts an interpreter value to the Go type t, the reverse of
+// ToValue.
+func fromValue(value any, t reflect.Type) (reflect.Value, error) {
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("Expected %s, got %s", valueTypeName(t), TypeName(value))
+	}
+
+	if t == functionType {
+		if _, ok := value.(Function); ok {
+			return reflect.ValueOf(value), nil
+		}
+		return mismatch()
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		rv := reflect.ValueOf(value)
+		if !rv.Type().Implements(t) {
+			return mismatch()
+		}
+		return rv.Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(int64)
+		if !ok {
+			return mismatch()
+		}
+		rv := reflect.New(t).Elem()
+		if rv.OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %v", n, t)
+		}
+		rv.SetInt(n)
+		return rv, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := value.(int64)
+		if !ok {
+			return mismatch()
+		}
+		rv := reflect.New(t).Elem()
+		if n < 0 || rv.OverflowUint(uint64(n)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %v", n, t)
+		}
+		rv.SetUint(uint64(n))
+		return rv, nil
+	case reflect.Float32, reflect.Float64:
+		rv := reflect.New(t).Elem()
+		switch n := value.(type) {
+		case int64:
+			rv.SetFloat(float64(n))
+		case float64:
+			rv.SetFloat(n)
+		default:
+			return mismatch()
+		}
+		return rv, nil
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(s).Convert(t), nil
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return mismatch()
+		}
+		return reflect.ValueOf(b).Convert(t), nil
+	case reflect.Slice:
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		a, ok := value.(*Array)
+		if !ok {
+			return mismatch()
+		}
+		rv := reflect.MakeSlice(t, a.Len(), a.Len())
+		for i, element := range a.Elements() {
+			converted, err := fromValue(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			rv.Index(i).Set(converted)
+		}
+		return rv, nil
+	case reflect.Map:
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		m, ok := value.(*Map)
+		if !ok {
+			return mismatch()
+		}
+		rv := reflect.MakeMapWithSize(t, m.Len())
+		for _, k := range m.Keys() {
+			key, err := fromValue(k, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v, _, _ := m.Get(k)
+			element, err := fromValue(v, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			rv.SetMapIndex(key, element)
+		}
+		return rv, nil
+	case reflect.Pointer:
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		rv := reflect.ValueOf(value)
+		if rv.Type() == t {
+			return rv, nil
+		}
+		return mismatch()
+	}
+
+	return reflect.Value{}, fmt.Errorf("Unsupported parameter type %v", t)
+}
+
+// valueTypeName names the interpreter type that converts to the Go type t.
+func valueTypeName(t reflect.Type) string {
+	if t == functionType {
+		return "Function"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "Int"
+	case reflect.Float32, reflect.Float64:
+		return "Float"
+	case reflect.String:
+		return "String"
+	case reflect.Bool:
+		return "Bool"
+	case reflect.Slice:
+		return "Array"
+	case reflect.Map:
+		return "Map"
+	}
+	return t.String()
+}
+
+// wrapFunc turns the Go function fn into a Function, see
+// Interpreter.RegisterFunc.
+func wrapFunc(name string, fn any) (Function, error) {
+	rv := reflect.ValueOf(fn)
+	if fn == nil || rv.Kind() != reflect.Func {
+		return Function{}, fmt.Errorf("Expected a function for %s, got %T", name, fn)
+	}
+	t := rv.Type()
+	if t.IsVariadic() {
+		return Function{}, fmt.Errorf("Variadic function %s is not supported", name)
+	}
+
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	results := t.NumOut()
+	if returnsError {
+		results--
+	}
+	if results > 1 {
+		return Function{}, fmt.Errorf("Function %s returns more than one value", name)
+	}
+
+	return Function{
+		Arity: t.NumIn(),
+		Call: func(_ *Environment, args []any) (any, error) {
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				converted, err := fromValue(arg, t.In(i))
+				if err != nil {
+					return nil, fmt.Errorf("Argument %d of %s: %v", i+1, name, err)
+				}
+				in[i] = converted
+			}
+
+			out := rv.Call(in)
+			if returnsError {
+				if err, _ := out[len(out)-1].Interface().(error); err != nil {
+					return nil, err
+				}
+			}
+			if results == 0 {
+				return nil, nil
+			}
+			return ToValue(out[0].Interface())
+		},
+	}, nil
+}
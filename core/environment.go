@@ -1,28 +1,52 @@
 package core
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+)
 
-type Environment []map[string]any
-
-func (env *Environment) push(v map[string]any) Environment {
-	*env = append(*env, v)
-	return *env
+// Environment is a single lexical scope. Scopes are linked to the scope
+// they were created in, so a Function can keep the chain it was defined
+// in alive after the defining block has finished.
+//
+// Globals are kept by name in values. Blocks and function calls hold their
+// locals in slots, at the indices the Resolver assigned to them.
+type Environment struct {
+	values    map[string]any
+	slots     []any
+	enclosing *Environment
+	exec      *execution
 }
 
-func (env *Environment) pop() map[string]any {
-	l := len(*env)
-	if l == 0 {
-		panic("Cannot pop from an empty environment")
+// undeclared fills a slot until the declaration of its variable has run.
+type undeclared struct{}
+
+func NewEnvironment(enclosing *Environment) *Environment {
+	env := &Environment{
+		values:    make(map[string]any),
+		enclosing: enclosing,
+	}
+	if enclosing != nil {
+		env.exec = enclosing.exec
 	}
+	return env
+}
 
-	res := (*env)[l-1]
-	*env = (*env)[:l-1]
-	return res
+func newScope(enclosing *Environment, size int) *Environment {
+	slots := make([]any, size)
+	for i := range slots {
+		slots[i] = undeclared{}
+	}
+	return &Environment{
+		slots:     slots,
+		enclosing: enclosing,
+		exec:      enclosing.exec,
+	}
 }
 
-func (env Environment) findVar(name string) (any, error) {
-	for i := len(env) - 1; i >= 0; i-- {
-		if val, ok := env[i][name]; ok {
+func (env *Environment) findVar(name string) (any, error) {
+	for scope := env; scope != nil; scope = scope.enclosing {
+		if val, ok := scope.values[name]; ok {
 			return val, nil
 		}
 	}
@@ -31,9 +55,9 @@ func (env Environment) findVar(name string) (any, error) {
 }
 
 func (env *Environment) setVar(name string, value any) error {
-	for i := len(*env) - 1; i >= 0; i-- {
-		if _, ok := (*env)[i][name]; ok {
-			(*env)[i][name] = value
+	for scope := env; scope != nil; scope = scope.enclosing {
+		if _, ok := scope.values[name]; ok {
+			scope.values[name] = value
 			return nil
 		}
 	}
@@ -42,20 +66,87 @@ func (env *Environment) setVar(name string, value any) error {
 }
 
 func (env *Environment) declareVar(name string, value any) {
-	l := len(*env)
-	(*env)[l-1][name] = value
+	if env.values == nil {
+		env.values = make(map[string]any)
+	}
+	env.values[name] = value
 }
 
-func DefaultEnvironment() Environment {
-	env := make(Environment, 1)
-	env[0] = make(map[string]any)
-	env[0]["print"] = Function{
-		Arity: 1,
-		Call: func(_ Environment, args []any) (any, error) {
-			fmt.Println(args[0])
-			return nil, nil
-		},
+func (env *Environment) ancestor(depth int) *Environment {
+	for i := 0; i < depth; i++ {
+		env = env.enclosing
 	}
-
 	return env
 }
+
+// lookup reads a variable through its Binding, or by name when the
+// Resolver found it to be global.
+func (env *Environment) lookup(name string, binding *Binding) (any, error) {
+	if binding == nil {
+		return env.findVar(name)
+	}
+
+	value := env.ancestor(binding.Depth).slots[binding.Slot]
+	if _, ok := value.(undeclared); ok {
+		return nil, fmt.Errorf("Variable %s not found", name)
+	}
+	return value, nil
+}
+
+func (env *Environment) assign(name string, binding *Binding, value any) error {
+	if binding == nil {
+		return env.setVar(name, value)
+	}
+
+	scope := env.ancestor(binding.Depth)
+	if _, ok := scope.slots[binding.Slot].(undeclared); ok {
+		return fmt.Errorf("Variable %s not found", name)
+	}
+	scope.slots[binding.Slot] = value
+	return nil
+}
+
+func (env *Environment) declare(name string, binding *Binding, value any) {
+	if binding == nil {
+		env.declareVar(name, value)
+		return
+	}
+	env.ancestor(binding.Depth).slots[binding.Slot] = value
+}
+
+// Get looks name up in this scope and every enclosing one.
+func (env *Environment) Get(name string) (any, error) {
+	return env.findVar(name)
+}
+
+// Set assigns to the nearest existing binding of name.
+func (env *Environment) Set(name string, value any) error {
+	return env.setVar(name, value)
+}
+
+// Declare binds name in this scope, shadowing any enclosing binding.
+func (env *Environment) Declare(name string, value any) {
+	env.declareVar(name, value)
+}
+
+// Names returns the names declared by name in this scope, not counting
+// enclosing ones, in lexicographic order.
+func (env *Environment) Names() []string {
+	names := make([]string, 0, len(env.values))
+	for name := range env.values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Enclosing returns the scope this one was created in, or nil.
+func (env *Environment) Enclosing() *Environment {
+	return env.enclosing
+}
+
+// DefaultEnvironment returns the globals of a new Interpreter, with every
+// builtin writing to os.Stdout and os.Stderr.
+func DefaultEnvironment() *Environment {
+	return NewInterpreter().globals
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Span locates a run of source text. Lines and columns are 1-based and
+// columns count runes, Length is the number of runes covered.
+type Span struct {
+	File   string
+	Line   int
+	Column int
+	Length int
+}
+
+func (span Span) String() string {
+	if span.File != "" {
+		return fmt.Sprintf("Line %d, Column %d in %s", span.Line, span.Column, span.File)
+	}
+	return fmt.Sprintf("Line %d, Column %d", span.Line, span.Column)
+}
+
+// ScanError reports source text the scanner could not turn into a token.
+type ScanError struct {
+	Span    Span
+	Message string
+}
+
+func (err *ScanError) Error() string {
+	return fmt.Sprintf("[ERROR] %s at %v", err.Message, err.Span)
+}
+
+// ParseError reports a token sequence that does not form a valid program.
+type ParseError struct {
+	Span    Span
+	Message string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("[ERROR] %s at %v", err.Message, err.Span)
+}
+
+// ResolveError reports a misuse of a variable that the Resolver found
+// before the program started running.
+type ResolveError struct {
+	Span    Span
+	Message string
+}
+
+func (err *ResolveError) Error() string {
+	return fmt.Sprintf("[ERROR] %s at %v", err.Message, err.Span)
+}
+
+// RuntimeError reports a failure while interpreting a program, located at
+// the token of the expression that failed.
+type RuntimeError struct {
+	Span    Span
+	Message string
+}
+
+func (err *RuntimeError) Error() string {
+	return fmt.Sprintf("[ERROR] %s at %v", err.Message, err.Span)
+}
+
+// ErrorList collects every diagnostic reported by a single scan or parse,
+// in source order.
+type ErrorList []error
+
+func (list ErrorList) Error() string {
+	messages := make([]string, len(list))
+	for i, err := range list {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (list ErrorList) Unwrap() []error {
+	return list
+}
+
+func newRuntimeError(token Token, format string, args ...any) error {
+	return &RuntimeError{
+		Span:    token.Span(),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// wrapRuntimeError attaches the location of token to an error raised by a
+// helper or builtin. Errors that already carry a location, control flow
+// signals and exits pass through untouched.
+func wrapRuntimeError(token Token, err error) error {
+	switch err.(type) {
+	case returnSignal, breakSignal, continueSignal:
+		return err
+	}
+	if _, ok := ErrorSpan(err); ok || IsExit(err) {
+		return err
+	}
+	return &RuntimeError{
+		Span:    token.Span(),
+		Message: err.Error(),
+	}
+}
+
+// Located is implemented by every error that knows where in the source it
+// was raised. ErrorSpan and FormatError use it to find the location.
+type Located interface {
+	error
+	Location() Span
+}
+
+func (err *ScanError) Location() Span    { return err.Span }
+func (err *ParseError) Location() Span   { return err.Span }
+func (err *ResolveError) Location() Span { return err.Span }
+func (err *RuntimeError) Location() Span { return err.Span }
+
+// ErrorSpan returns where err, or the first error it wraps that knows its
+// location, was raised.
+func ErrorSpan(err error) (Span, bool) {
+	var loc Located
+	if errors.As(err, &loc) {
+		return loc.Location(), true
+	}
+	return Span{}, false
+}
+
+// FormatError renders err followed by the offending line of source and a
+// caret marking the span, e.g.
+//
+//	[ERROR] Variable y not found at Line 2, Column 6
+//	   2 | x := y + 1
+//	     |      ^
+//
+// Errors that wrap several errors, such as ErrorList, are rendered one
+// after another.
+func FormatError(err error, source string) string {
+	return formatError(err, func(Span) string {
+		return source
+	})
+}
+
+// formatError renders err with the source that sourceOf returns for the
+// span of each error.
+func formatError(err error, sourceOf func(Span) string) string {
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		var rendered []string
+		for _, inner := range multi.Unwrap() {
+			rendered = append(rendered, formatError(inner, sourceOf))
+		}
+		return strings.Join(rendered, "\n")
+	}
+
+	span, ok := ErrorSpan(err)
+	lines := strings.Split(sourceOf(span), "\n")
+	if !ok || span.Line < 1 || span.Line > len(lines) {
+		return err.Error()
+	}
+
+	line := []rune(strings.TrimRight(lines[span.Line-1], "\r"))
+	gutter := fmt.Sprintf("%4d | ", span.Line)
+
+	// Reuse tabs from the source line so the caret lines up regardless of
+	// the terminal's tab width.
+	var marker strings.Builder
+	for i := 0; i < span.Column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			marker.WriteRune('\t')
+		} else {
+			marker.WriteRune(' ')
+		}
+	}
+	marker.WriteString(strings.Repeat("^", max(span.Length, 1)))
+
+	var b strings.Builder
+	b.WriteString(err.Error())
+	b.WriteString("\n")
+	b.WriteString(gutter)
+	b.WriteString(string(line))
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat(" ", len(gutter)-2))
+	b.WriteString("| ")
+	b.WriteString(marker.String())
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		target any
+		span   Span
+	}{
+		{"scan", `x := 1 @ 2`, new(*ScanError), Span{Line: 1, Column: 8, Length: 1}},
+		{"parse", "x := 1\nx := )", new(*ParseError), Span{Line: 2, Column: 6, Length: 1}},
+		{"runtime", "x := 1\ny := x + true", new(*RuntimeError), Span{Line: 2, Column: 8, Length: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interp := NewInterpreter()
+			program, err := interp.Parse("", test.src)
+			if err == nil {
+				_, err = interp.Run(program)
+			}
+			if !errors.As(err, test.target) {
+				t.Fatalf("Got %T, want %T", err, test.target)
+			}
+			span, ok := ErrorSpan(err)
+			if !ok || span != test.span {
+				t.Errorf("ErrorSpan is %+v, %v, want %+v", span, ok, test.span)
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	err := &RuntimeError{Span: Span{Line: 2, Column: 7, Length: 2}, Message: "Something failed"}
+
+	got := FormatError(err, "x := 1\n\ty := xy + 1\n")
+	want := "[ERROR] Something failed at Line 2, Column 7\n" +
+		"   2 | \ty := xy + 1\n" +
+		"     | \t     ^^"
+	if got != want {
+		t.Errorf("Got\n%s\nwant\n%s", got, want)
+	}
+
+	// Without a matching line only the message is left.
+	if got := FormatError(err, "x := 1"); got != err.Error() {
+		t.Errorf("Got %q, want %q", got, err.Error())
+	}
+	if got := FormatError(errors.New("plain"), "x := 1"); got != "plain" {
+		t.Errorf("Got %q, want %q", got, "plain")
+	}
+}
+
+func TestWrappedErrorSpan(t *testing.T) {
+	inner := &ParseError{Span: Span{Line: 3, Column: 1, Length: 1}, Message: "Bad"}
+	err := ErrorList{errors.New("first"), inner}
+	span, ok := ErrorSpan(err)
+	if !ok || span != inner.Span {
+		t.Errorf("ErrorSpan is %+v, %v, want %+v", span, ok, inner.Span)
+	}
+}
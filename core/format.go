@@ -0,0 +1,559 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// indentation is the text nested blocks are indented with.
+const indentation = "  "
+
+// Format returns src in the canonical layout: one statement per line,
+// blocks indented by two spaces with the opening brace on the line of its
+// statement, single spaces around binary operators and after commas, and
+// at most one blank line in a row. Comments are kept in place. Source
+// that does not parse is not formatted and the errors are returned, file
+// is only used to locate them.
+func Format(file string, src string) (string, error) {
+	scanner := CreateScanner(src)
+	scanner.File = file
+	scanErr := scanner.ScanTokens()
+	parser := CreateParser(scanner.Tokens)
+	program, err := parser.Parse()
+	if scanErr != nil || err != nil {
+		return "", errors.Join(scanErr, err)
+	}
+
+	p := &printer{comments: scanner.Comments}
+	return p.program(program), nil
+}
+
+// printer writes a program as source. Comments are not part of the tree,
+// so they are emitted from a queue whenever printing reaches their line.
+type printer struct {
+	b        strings.Builder
+	indent   int
+	comments []Comment
+	// line is the source line of the last statement or comment written,
+	// used to keep blank lines.
+	line int
+	// start is set at the beginning of a block, where blank lines are
+	// dropped.
+	start bool
+}
+
+// program writes the statements of a program, the remaining comments and
+// a final line break, and returns the output.
+func (p *printer) program(program []Expr) string {
+	p.statements(program, 0)
+	p.comment(func(Comment) bool { return true })
+	if p.b.Len() > 0 {
+		p.b.WriteString("\n")
+	}
+	return p.b.String()
+}
+
+// newline starts a new indented line, after a blank one when blank is set.
+// Nothing is written at the very beginning of the output.
+func (p *printer) newline(blank bool) {
+	if p.b.Len() > 0 {
+		if blank && !p.start {
+			p.b.WriteString("\n")
+		}
+		p.b.WriteString("\n")
+	}
+	p.b.WriteString(strings.Repeat(indentation, p.indent))
+	p.start = false
+}
+
+func (p *printer) write(s string) {
+	p.b.WriteString(s)
+}
+
+// comment writes every queued comment accepted by before on its own line.
+func (p *printer) comment(before func(Comment) bool) {
+	for len(p.comments) > 0 && before(p.comments[0]) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.newline(p.line > 0 && c.Span.Line > p.line+1)
+		p.write(strings.TrimRight(c.Text, " \t\r"))
+		p.line = c.Span.Line
+	}
+}
+
+// leading writes the comments on the lines before line.
+func (p *printer) leading(line int) {
+	p.comment(func(c Comment) bool {
+		return c.Span.Line < line
+	})
+}
+
+// trailing writes the comments up to line after what was just printed. A
+// comment on line itself stays on the same output line.
+func (p *printer) trailing(line int) {
+	if line == 0 {
+		return
+	}
+	if len(p.comments) > 0 && p.comments[0].Span.Line == line {
+		p.write(" ")
+		p.write(strings.TrimRight(p.comments[0].Text, " \t\r"))
+		p.comments = p.comments[1:]
+	}
+	p.comment(func(c Comment) bool {
+		return c.Span.Line <= line
+	})
+	p.line = max(p.line, line)
+}
+
+// statements writes a list of statements, each on its own line, followed
+// by the comments before end when it is not 0. A statement is ended with
+// ';' when the next one would otherwise continue it.
+func (p *printer) statements(exprs []Expr, end int) {
+	for i, expr := range exprs {
+		first, last := lineRange(expr)
+		p.leading(first)
+		p.newline(p.line > 0 && first > p.line+1)
+		p.expr(expr)
+		if i+1 < len(exprs) && continues(exprs[i+1]) {
+			p.write(";")
+		}
+		p.line = max(p.line, first)
+		p.trailing(last)
+	}
+	if end > 0 {
+		p.leading(end)
+	}
+}
+
+func (p *printer) expr(expr Expr) {
+	switch e := expr.(type) {
+	case LiteralExpr:
+		p.write(literal(e.Token))
+	case AssignExpr:
+		p.write(literal(e.Name))
+		p.write(" " + operatorSymbols[e.Operator.Type] + " ")
+		p.expr(e.Value)
+	case BinaryExpr:
+		left, right := operands(e.Operator.Type)
+		p.operand(e.Left, left)
+		p.write(" " + operatorSymbol(e.Operator.Type) + " ")
+		p.operand(e.Right, right)
+	case LogicalExpr:
+		left, right := operands(e.Operator.Type)
+		p.operand(e.Left, left)
+		p.write(" " + operatorSymbol(e.Operator.Type) + " ")
+		p.operand(e.Right, right)
+	case UnaryExpr:
+		p.write(operatorSymbol(e.Operator.Type))
+		p.operand(e.Operand, precCall)
+	case GroupingExpr:
+		p.write("(")
+		p.expr(e.Inner)
+		p.write(")")
+	case BlockExpr:
+		if loop, ok := forLoop(e); ok {
+			p.forLoop(e.Body[0], loop)
+		} else {
+			p.block(e)
+		}
+	case IfExpr:
+		p.write("if (")
+		p.expr(e.Condition)
+		p.write(")")
+		_, header := lineRange(e.Condition)
+		then := e.Then
+		if e.Else != nil && openIf(then) {
+			// The else would be read as belonging to the inner if.
+			then = GroupingExpr{Inner: then}
+		}
+		broken := p.body(header, then)
+		if e.Else == nil {
+			return
+		}
+		if !broken {
+			p.write(" else")
+		} else {
+			p.newline(false)
+			p.write("else")
+		}
+		if _, ok := e.Else.(IfExpr); ok || !broken {
+			p.write(" ")
+			p.expr(e.Else)
+		} else {
+			p.indented(e.Else)
+		}
+	case WhileExpr:
+		if e.Increment != nil {
+			// A tree built by hand may have an increment outside of the
+			// block of a for loop, it gets an initializer that does nothing.
+			p.forLoop(LiteralExpr{Token: Token{Type: TRUE}}, e)
+			return
+		}
+		p.label(e.Label)
+		p.write("while (")
+		p.expr(e.Condition)
+		p.write(")")
+		_, header := lineRange(e.Condition)
+		p.body(header, loopBody(e))
+	case CallExpr:
+		p.operand(e.Callee, precCall)
+		p.list("(", ")", e.Paren, e.RightParen, len(e.Args), func(i int) (Token, Token) {
+			return tokenRange(e.Args[i])
+		}, func(i int) {
+			p.expr(e.Args[i])
+		})
+	case FnDeclExpr:
+		p.write("fn " + literal(e.Name))
+		p.params(e.Paren, e.Params, e.RightParen)
+		p.body(e.RightParen.Line, e.Body)
+	case FnExpr:
+		p.write("fn")
+		p.params(e.Paren, e.Params, e.RightParen)
+		p.body(e.RightParen.Line, e.Body)
+	case ArrayInitExpr:
+		p.list("[", "]", e.Bracket, e.RightBracket, len(e.Elements), func(i int) (Token, Token) {
+			return tokenRange(e.Elements[i])
+		}, func(i int) {
+			p.expr(e.Elements[i])
+		})
+	case IndexExpr:
+		p.operand(e.Object, precCall)
+		p.write("[")
+		p.expr(e.Index)
+		p.write("]")
+	case IndexAssignExpr:
+		p.operand(e.Object, precCall)
+		p.write("[")
+		p.expr(e.Index)
+		p.write("] " + operatorSymbols[e.Operator.Type] + " ")
+		p.expr(e.Value)
+	case MapInitExpr:
+		if len(e.Keys) == 0 {
+			p.write("{:}")
+			return
+		}
+		p.list("{", "}", e.Brace, e.RightBrace, len(e.Keys), func(i int) (Token, Token) {
+			first, _ := tokenRange(e.Keys[i])
+			_, last := tokenRange(e.Values[i])
+			return first, last
+		}, func(i int) {
+			p.expr(e.Keys[i])
+			p.write(": ")
+			p.expr(e.Values[i])
+		})
+	case ImportExpr:
+		p.write("import ")
+		if e.Name.Type == IDENTIFIER {
+			p.write(literal(e.Name) + " ")
+		}
+		p.write(literal(e.Path))
+	case GetExpr:
+		p.operand(e.Object, precCall)
+		p.write("." + literal(e.Name))
+	case ReturnExpr:
+		p.write("return")
+		if e.Value != nil {
+			p.write(" ")
+			p.expr(e.Value)
+		}
+	case BreakExpr:
+		p.write("break")
+		if name := labelName(e.Label); name != "" {
+			p.write(" " + name)
+		}
+	case ContinueExpr:
+		p.write("continue")
+		if name := labelName(e.Label); name != "" {
+			p.write(" " + name)
+		}
+	}
+}
+
+// operand writes expr where the grammar expects an expression that binds
+// at least as tightly as prec, in parentheses when it does not.
+func (p *printer) operand(expr Expr, prec int) {
+	if precedence(expr) >= prec {
+		p.expr(expr)
+		return
+	}
+	p.write("(")
+	p.expr(expr)
+	p.write(")")
+}
+
+// body writes the body of a statement after its header, which ends on
+// the source line header. Blocks and bodies that started on the header
+// line follow on the same line, any other body is indented on a line of
+// its own. body reports whether it started a new line.
+func (p *printer) body(header int, body Expr) bool {
+	first, _ := lineRange(body)
+	if block, ok := body.(BlockExpr); ok && block.Brace.Type == LEFT_BRACE || header == 0 || first <= header {
+		p.write(" ")
+		p.expr(body)
+		return false
+	}
+	p.indented(body)
+	return true
+}
+
+// indented writes expr on a new line, indented one level deeper.
+func (p *printer) indented(expr Expr) {
+	p.indent++
+	p.newline(false)
+	p.expr(expr)
+	p.indent--
+}
+
+// block writes a block with one statement per line. A block with a single
+// statement that was written on one line stays on one line.
+func (p *printer) block(block BlockExpr) {
+	p.write("{")
+	if len(block.Body) == 0 && !p.commentBefore(block.RightBrace.Line) {
+		p.write("}")
+		return
+	}
+	if len(block.Body) == 1 && block.Brace.Line == block.RightBrace.Line && block.Brace.Line != 0 {
+		p.write(" ")
+		p.expr(block.Body[0])
+		p.write(" }")
+		return
+	}
+
+	p.trailing(block.Brace.Line)
+	p.indent++
+	p.start = true
+	p.statements(block.Body, block.RightBrace.Line)
+	p.indent--
+	p.newline(false)
+	p.write("}")
+	p.line = max(p.line, block.RightBrace.Line)
+}
+
+// commentBefore reports whether a comment is queued before line.
+func (p *printer) commentBefore(line int) bool {
+	return len(p.comments) > 0 && p.comments[0].Span.Line < line
+}
+
+// list writes n items between open and close, separated by commas. Items
+// are put on lines of their own when the first one started on a later
+// line than the opening token in the source, or when a comment follows
+// one of them, which has to end its line. bounds returns the first and
+// the last token of an item.
+func (p *printer) list(open string, close string, opener Token, closer Token, n int, bounds func(int) (Token, Token), write func(int)) {
+	p.write(open)
+	if n == 0 {
+		p.write(close)
+		return
+	}
+
+	lines := func(i int) (int, int) {
+		first, last := bounds(i)
+		return first.Line, endLine(last)
+	}
+	first, _ := lines(0)
+	if (first <= opener.Line || opener.Line == 0) && !p.commentBetween(opener, closer, n, bounds) {
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			write(i)
+		}
+		p.write(close)
+		return
+	}
+
+	if first > opener.Line {
+		p.trailing(opener.Line)
+	}
+	p.indent++
+	for i := 0; i < n; i++ {
+		first, last := lines(i)
+		p.leading(first)
+		p.newline(false)
+		write(i)
+		if i < n-1 {
+			p.write(",")
+		}
+		p.line = max(p.line, first)
+		// A comment on a line shared with the next item follows that one.
+		if next, _ := lines(min(i+1, n-1)); i == n-1 || next > last {
+			p.trailing(last)
+		}
+	}
+	if closer.Line > 0 {
+		p.leading(closer.Line)
+	}
+	p.indent--
+	p.newline(false)
+	p.write(close)
+}
+
+// commentBetween reports whether a queued comment is inside the list from
+// opener to closer but outside of all of its items. Comments inside an
+// item are written by the item.
+func (p *printer) commentBetween(opener Token, closer Token, n int, bounds func(int) (Token, Token)) bool {
+	if opener.Line == 0 || closer.Line == 0 {
+		return false
+	}
+	for _, c := range p.comments {
+		if !inside(c.Span, opener, closer) {
+			if precedes(closer.Span(), c.Span) {
+				return false
+			}
+			continue
+		}
+		between := true
+		for i := 0; i < n && between; i++ {
+			first, last := bounds(i)
+			between = !inside(c.Span, first, last)
+		}
+		if between {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) params(paren Token, params []Token, rightParen Token) {
+	p.list("(", ")", paren, rightParen, len(params), func(i int) (Token, Token) {
+		return params[i], params[i]
+	}, func(i int) {
+		p.write(literal(params[i]))
+	})
+}
+
+func (p *printer) label(label Token) {
+	if name := labelName(label); name != "" {
+		p.write(name + ": ")
+	}
+}
+
+func (p *printer) forLoop(initializer Expr, loop WhileExpr) {
+	p.label(loop.Label)
+	p.write("for (")
+	p.expr(initializer)
+	p.write("; ")
+	p.expr(loop.Condition)
+	p.write("; ")
+	p.expr(loop.Increment)
+	p.write(")")
+	_, header := lineRange(loop.Increment)
+	p.body(header, loopBody(loop))
+}
+
+// forLoop recognizes the block a for loop is parsed into, the initializer
+// followed by a while loop with an increment.
+func forLoop(block BlockExpr) (WhileExpr, bool) {
+	if block.Brace.Type == LEFT_BRACE || len(block.Body) != 2 {
+		return WhileExpr{}, false
+	}
+	loop, ok := block.Body[1].(WhileExpr)
+	return loop, ok && loop.Increment != nil
+}
+
+// loopBody returns the body of a loop as written, without the block the
+// parser wraps it in.
+func loopBody(loop WhileExpr) Expr {
+	if block, ok := loop.Body.(BlockExpr); ok && block.Brace.Type != LEFT_BRACE && len(block.Body) == 1 {
+		return block.Body[0]
+	}
+	return loop.Body
+}
+
+// openIf reports whether expr ends in an if without an else, which would
+// take an else written right after expr as its own.
+func openIf(expr Expr) bool {
+	switch e := expr.(type) {
+	case IfExpr:
+		return e.Else == nil || openIf(e.Else)
+	case WhileExpr:
+		return openIf(loopBody(e))
+	case BlockExpr:
+		if loop, ok := forLoop(e); ok {
+			return openIf(loopBody(loop))
+		}
+	case FnDeclExpr:
+		return openIf(e.Body)
+	case FnExpr:
+		return openIf(e.Body)
+	case AssignExpr:
+		return openIf(e.Value)
+	case IndexAssignExpr:
+		return openIf(e.Value)
+	case ReturnExpr:
+		return e.Value != nil && openIf(e.Value)
+	}
+	return false
+}
+
+// literal writes a literal token the way the scanner reads it back.
+func literal(token Token) string {
+	switch token.Type {
+	case TRUE:
+		return "true"
+	case FALSE:
+		return "false"
+	}
+	switch value := token.Value.(type) {
+	case string:
+		if token.Type == STRING {
+			return `"` + value + `"`
+		}
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		s := strconv.FormatFloat(value, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	return token.String()
+}
+
+// lineRange returns the first and the last source line of expr, or 0 for
+// nodes without any tokens.
+func lineRange(expr Expr) (int, int) {
+	first, last := tokenRange(expr)
+	return first.Line, endLine(last)
+}
+
+// tokenRange returns the first and the last token of expr in the source,
+// or zero Tokens for nodes without any positioned tokens.
+func tokenRange(expr Expr) (Token, Token) {
+	var first, last Token
+	WalkTokens(expr, func(token Token) {
+		if token.Line == 0 {
+			return
+		}
+		if first.Line == 0 || precedes(token.Span(), first.Span()) {
+			first = token
+		}
+		if last.Line == 0 || precedes(last.Span(), token.Span()) {
+			last = token
+		}
+	})
+	return first, last
+}
+
+// endLine returns the line token ends on. Strings may span lines, their
+// token is on the line they start.
+func endLine(token Token) int {
+	if value, ok := token.Value.(string); ok && token.Type == STRING {
+		return token.Line + strings.Count(value, "\n")
+	}
+	return token.Line
+}
+
+// precedes reports whether a starts before b.
+func precedes(a Span, b Span) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// inside reports whether span starts after the token from and before the
+// token to.
+func inside(span Span, from Token, to Token) bool {
+	return precedes(from.Span(), span) && precedes(span, to.Span())
+}
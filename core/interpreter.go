@@ -0,0 +1,269 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Interpreter runs lagn programs on behalf of a Go host. Each Interpreter
+// has its own globals and builtins, so any number of them can be used in
+// one process as long as a single Interpreter is not shared between
+// goroutines.
+//
+//	interp := core.NewInterpreter()
+//	interp.Set("limit", 10)
+//	interp.RegisterFunc("lookup", func(key string) (int, error) { ... })
+//	result, err := interp.Eval(`lookup("a") < limit`)
+type Interpreter struct {
+	// Stdout and Stderr receive the output of print and eprint, Stdin is
+	// read by the io module. They can be replaced at any time.
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+	// Args is returned by os.args, it starts out empty.
+	Args []string
+	// Capabilities selects what the io and os modules may do. It starts
+	// out as AllCapabilities, a sandboxed host removes what it does not
+	// want to allow.
+	Capabilities Capability
+	// Limits applies to every following run. Exceeding one of them ends
+	// the run with a StepLimitError, CallDepthError or ArraySizeError.
+	Limits Limits
+	// SearchPath lists the directories searched for modules that are not
+	// found next to the importing file. It starts out as the directories
+	// in the LAGN_PATH environment variable.
+	SearchPath []string
+
+	builtins *Environment
+	globals  *Environment
+	exec     *execution
+	modules  map[string]*Module
+	natives  map[string]*Module
+	loading  []string
+	sources  map[string]string
+
+	stdinReader *bufio.Reader
+	stdinSource io.Reader
+}
+
+func NewInterpreter() *Interpreter {
+	interp := &Interpreter{
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Stdin:  os.Stdin,
+		Limits: Limits{
+			MaxCallDepth: DefaultMaxCallDepth,
+		},
+		Capabilities: AllCapabilities,
+		builtins:     NewEnvironment(nil),
+		modules:      make(map[string]*Module),
+		natives:      make(map[string]*Module),
+		sources:      make(map[string]string),
+	}
+	if path := os.Getenv("LAGN_PATH"); path != "" {
+		interp.SearchPath = filepath.SplitList(path)
+	}
+
+	interp.exec = &execution{
+		interp: interp,
+		ctx:    context.Background(),
+	}
+	interp.builtins.exec = interp.exec
+	interp.globals = NewEnvironment(interp.builtins)
+	interp.defineBuiltins()
+	interp.RegisterModule("math", mathModule())
+	interp.RegisterModule("strings", stringsModule())
+	interp.RegisterModule("io", ioModule(interp))
+	interp.RegisterModule("os", osModule(interp))
+	return interp
+}
+
+// Globals returns the scope that top-level declarations of every evaluated
+// program are stored in.
+func (interp *Interpreter) Globals() *Environment {
+	return interp.globals
+}
+
+// Parse scans, parses and resolves src against the current globals. file
+// is only used to locate errors and may be empty.
+func (interp *Interpreter) Parse(file string, src string) ([]Expr, error) {
+	return interp.parse(file, src, interp.globals)
+}
+
+func (interp *Interpreter) parse(file string, src string, globals *Environment) ([]Expr, error) {
+	scanner := CreateScanner(src)
+	scanner.File = file
+	scanErr := scanner.ScanTokens()
+	parser := CreateParser(scanner.Tokens)
+	program, err := parser.Parse()
+	if scanErr != nil || err != nil {
+		return nil, errors.Join(scanErr, err)
+	}
+
+	resolver := CreateResolver(globals)
+	return resolver.Resolve(program)
+}
+
+// Run interprets a program returned by Parse and returns the value of its
+// last expression.
+func (interp *Interpreter) Run(program []Expr) (any, error) {
+	return interp.RunContext(context.Background(), program)
+}
+
+// RunContext is like Run, but stops with a CanceledError once ctx is done.
+// The context is checked on every loop iteration and function call.
+func (interp *Interpreter) RunContext(ctx context.Context, program []Expr) (any, error) {
+	return interp.ExecuteContext(ctx, func() (any, error) {
+		var output any
+		for _, expr := range program {
+			var err error
+			output, err = expr.Interpret(interp.globals)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return output, nil
+	})
+}
+
+// ExecuteContext makes run one run of the Interpreter: ctx and Limits
+// apply to every step counted with the globals until run returns. It lets
+// another backend, like the bytecode VM, run programs under the same
+// limits as RunContext.
+func (interp *Interpreter) ExecuteContext(ctx context.Context, run func() (any, error)) (any, error) {
+	exec := interp.exec
+	previous := *exec
+	exec.ctx = ctx
+	exec.limits = interp.Limits
+	exec.steps = 0
+	defer func() {
+		// Runs started by a host function during another run share its
+		// call depth, the outer run continues with its own budget.
+		exec.ctx = previous.ctx
+		exec.limits = previous.limits
+		exec.steps = previous.steps
+	}()
+
+	return run()
+}
+
+// Eval runs src and returns the value of its last expression. Globals
+// declared by src stay visible to later calls.
+func (interp *Interpreter) Eval(src string) (any, error) {
+	return interp.EvalContext(context.Background(), src)
+}
+
+// EvalContext is like Eval with the cancellation of RunContext.
+func (interp *Interpreter) EvalContext(ctx context.Context, src string) (any, error) {
+	program, err := interp.Parse("", src)
+	if err != nil {
+		return nil, err
+	}
+	return interp.RunContext(ctx, program)
+}
+
+// EvalFile is like Eval for the contents of the file at path.
+func (interp *Interpreter) EvalFile(path string) (any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := interp.Parse(path, string(content))
+	if err != nil {
+		return nil, err
+	}
+	return interp.Run(program)
+}
+
+// Get returns the value of the global name.
+func (interp *Interpreter) Get(name string) (any, error) {
+	return interp.globals.findVar(name)
+}
+
+// Set declares or replaces the global name. Go values are converted with
+// ToValue.
+func (interp *Interpreter) Set(name string, value any) error {
+	v, err := ToValue(value)
+	if err != nil {
+		return err
+	}
+	interp.globals.declareVar(name, v)
+	return nil
+}
+
+// RegisterFunc makes the Go function fn callable as name, in the program
+// and in every module it imports. Arguments are converted to the parameter
+// types of fn, and its result back with ToValue. fn may return nothing, a
+// value, an error, or a value and an error; a non-nil error becomes a
+// runtime error at the call.
+func (interp *Interpreter) RegisterFunc(name string, fn any) error {
+	function, err := wrapFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	interp.builtins.declareVar(name, function)
+	return nil
+}
+
+func (interp *Interpreter) defineBuiltins() {
+	env := interp.builtins
+	// There is no literal for nil, builtins like find and io.readLine
+	// return it to signal that nothing was found.
+	env.declareVar("nil", nil)
+	env.declareVar("print", Function{
+		Arity: 1,
+		Call: func(_ *Environment, args []any) (any, error) {
+			fmt.Fprintln(interp.Stdout, args[0])
+			return nil, nil
+		},
+	})
+	env.declareVar("eprint", Function{
+		Arity: 1,
+		Call: func(_ *Environment, args []any) (any, error) {
+			fmt.Fprintln(interp.Stderr, args[0])
+			return nil, nil
+		},
+	})
+
+	env.declareVar("keys", Function{
+		Arity: 1,
+		Call: func(_ *Environment, args []any) (any, error) {
+			m, ok := args[0].(*Map)
+			if !ok {
+				return nil, fmt.Errorf("Expected Map, got %s", TypeName(args[0]))
+			}
+			return NewArray(m.Keys()), nil
+		},
+	})
+	env.declareVar("has", Function{
+		Arity: 2,
+		Call: func(_ *Environment, args []any) (any, error) {
+			m, ok := args[0].(*Map)
+			if !ok {
+				return nil, fmt.Errorf("Expected Map, got %s", TypeName(args[0]))
+			}
+			_, found, err := m.Get(args[1])
+			return found, err
+		},
+	})
+	env.declareVar("delete", Function{
+		Arity: 2,
+		Call: func(_ *Environment, args []any) (any, error) {
+			m, ok := args[0].(*Map)
+			if !ok {
+				return nil, fmt.Errorf("Expected Map, got %s", TypeName(args[0]))
+			}
+			return nil, m.Delete(args[1])
+		},
+	})
+
+	for name, function := range arrayBuiltins() {
+		env.declareVar(name, function)
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	interp := NewInterpreter()
+	if _, err := interp.Eval(`x := 20 fn double(n) n * 2`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Globals and functions stay visible to later calls.
+	got, err := interp.Eval(`double(x) + 2`)
+	if err != nil || got != int64(42) {
+		t.Errorf("Got %v, %v, want 42", got, err)
+	}
+	if x, err := interp.Get("x"); err != nil || x != int64(20) {
+		t.Errorf("Get(x) is %v, %v, want 20", x, err)
+	}
+	if _, err := interp.Get("missing"); err == nil {
+		t.Errorf("Get(missing) succeeded")
+	}
+
+	// Interpreters do not share globals.
+	if _, err := NewInterpreter().Eval(`x`); err == nil {
+		t.Errorf("A new Interpreter sees x")
+	}
+}
+
+func TestSet(t *testing.T) {
+	interp := NewInterpreter()
+	values := map[string]any{
+		"n":      int32(3),
+		"f":      float32(0.5),
+		"names":  []string{"a", "b"},
+		"scores": map[string]int{"a": 1},
+		"ptr":    new(int),
+		"none":   []int(nil),
+	}
+	for name, value := range values {
+		if err := interp.Set(name, value); err != nil {
+			t.Fatalf("Set(%s): %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{`n + f`, 3.5},
+		{`names[1] + #names`, "b2"},
+		{`scores["a"]`, int64(1)},
+		{`ptr`, int64(0)},
+		{`none == nil`, true},
+		{`n = 4 n`, int64(4)},
+	}
+	for _, test := range tests {
+		got, err := interp.Eval(test.src)
+		if err != nil || got != test.want {
+			t.Errorf("%s = %v, %v, want %v", test.src, got, err, test.want)
+		}
+	}
+
+	if err := interp.Set("ch", make(chan int)); err == nil {
+		t.Errorf("Set accepted a channel")
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	interp := NewInterpreter()
+	err := interp.RegisterFunc("lookup", func(key string) (int, error) {
+		if key == "" {
+			return 0, errors.New("Empty key")
+		}
+		return len(key), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []float64
+	err = interp.RegisterFunc("record", func(values []float64) {
+		seen = values
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := interp.Eval(`lookup("abc") < 4`)
+	if err != nil || got != true {
+		t.Errorf("Got %v, %v, want true", got, err)
+	}
+	if _, err := interp.Eval(`record([1, 2.5])`); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seen, []float64{1, 2.5}) {
+		t.Errorf("record got %v, want [1 2.5]", seen)
+	}
+
+	errorTests := map[string]string{
+		`lookup("")`: "[ERROR] Empty key at Line 1, Column 7",
+		`lookup(1)`:  "[ERROR] Argument 1 of lookup: Expected String, got Int at Line 1, Column 7",
+		`lookup()`:   "[ERROR] Arity does not match at Function lookup at Line 1, Column 7",
+	}
+	for src, want := range errorTests {
+		_, err := interp.Eval(src)
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: got error %v, want %q", src, err, want)
+		}
+	}
+}
+
+func TestRegisterFuncInvalid(t *testing.T) {
+	interp := NewInterpreter()
+	invalid := map[string]any{
+		"not a function": 1,
+		"variadic":       func(args ...int) {},
+		"two results":    func() (int, int) { return 0, 0 },
+	}
+	for name, fn := range invalid {
+		if err := interp.RegisterFunc("f", fn); err == nil {
+			t.Errorf("RegisterFunc accepted a function that is %s", name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Capability is a set of host resources the io and os modules may reach.
+// Calling a member whose capability is missing from
+// Interpreter.Capabilities is a runtime error.
+type Capability uint
+
+const (
+	// FileRead allows reading files and listing directories.
+	FileRead Capability = 1 << iota
+	// FileWrite allows creating, writing and appending to files.
+	FileWrite
+	// StdinRead allows reading the standard input.
+	StdinRead
+	// EnvRead allows reading environment variables.
+	EnvRead
+	// ProcessExit allows a program to end its run with an exit code.
+	ProcessExit
+	// ProcessRun allows starting subprocesses.
+	ProcessRun
+
+	// AllCapabilities is the set a new Interpreter starts out with.
+	AllCapabilities = FileRead | FileWrite | StdinRead | EnvRead | ProcessExit | ProcessRun
+)
+
+var capabilityNames = map[Capability]string{
+	FileRead:    "Reading files",
+	FileWrite:   "Writing files",
+	StdinRead:   "Reading stdin",
+	EnvRead:     "Reading the environment",
+	ProcessExit: "Exiting",
+	ProcessRun:  "Running subprocesses",
+}
+
+func (c Capability) String() string {
+	var names []string
+	for capability := FileRead; capability <= ProcessRun; capability <<= 1 {
+		if c&capability != 0 {
+			names = append(names, capabilityNames[capability])
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// require reports an error unless every capability in c is enabled.
+func (interp *Interpreter) require(c Capability) error {
+	if missing := c &^ interp.Capabilities; missing != 0 {
+		return fmt.Errorf("%v is disabled", missing)
+	}
+	return nil
+}
+
+// stdin returns a buffered reader for Stdin, shared between calls so
+// that nothing read ahead is lost. A new one is made whenever Stdin is
+// replaced.
+func (interp *Interpreter) stdin() *bufio.Reader {
+	if interp.stdinReader == nil || interp.stdinSource != interp.Stdin {
+		interp.stdinReader = bufio.NewReader(interp.Stdin)
+		interp.stdinSource = interp.Stdin
+	}
+	return interp.stdinReader
+}
+
+// ioModule returns the members of the io module. Paths are relative to the
+// working directory of the process.
+func ioModule(interp *Interpreter) map[string]any {
+	return map[string]any{
+		"read": func(path string) (string, error) {
+			if err := interp.require(FileRead); err != nil {
+				return "", err
+			}
+			content, err := os.ReadFile(path)
+			return string(content), err
+		},
+		"lines": func(path string) ([]string, error) {
+			if err := interp.require(FileRead); err != nil {
+				return nil, err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return splitLines(string(content)), nil
+		},
+		"list": func(path string) ([]string, error) {
+			if err := interp.require(FileRead); err != nil {
+				return nil, err
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name()
+			}
+			return names, nil
+		},
+		"exists": func(path string) (bool, error) {
+			if err := interp.require(FileRead); err != nil {
+				return false, err
+			}
+			_, err := os.Stat(path)
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return err == nil, err
+		},
+		"write": func(path string, content string) error {
+			if err := interp.require(FileWrite); err != nil {
+				return err
+			}
+			return os.WriteFile(path, []byte(content), 0o644)
+		},
+		"append": func(path string, content string) error {
+			if err := interp.require(FileWrite); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = file.WriteString(content)
+			return errors.Join(err, file.Close())
+		},
+
+		// readLine returns the next line of stdin without its line
+		// ending, or nil once stdin is exhausted.
+		"readLine": func() (any, error) {
+			if err := interp.require(StdinRead); err != nil {
+				return nil, err
+			}
+			line, err := interp.stdin().ReadString('\n')
+			if err == io.EOF && line == "" {
+				return nil, nil
+			} else if err != nil && err != io.EOF {
+				return nil, err
+			}
+			line = strings.TrimSuffix(line, "\n")
+			return strings.TrimSuffix(line, "\r"), nil
+		},
+		"readAll": func() (string, error) {
+			if err := interp.require(StdinRead); err != nil {
+				return "", err
+			}
+			content, err := io.ReadAll(interp.stdin())
+			return string(content), err
+		},
+	}
+}
+
+// splitLines splits text at line endings, accepting both "\n" and "\r\n".
+// A final line ending does not start another line.
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
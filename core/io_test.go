@@ -0,0 +1,143 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIOModule(t *testing.T) {
+	interp := NewInterpreter()
+	dir := t.TempDir()
+	if err := interp.Set("path", filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Set("dir", dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Set("content", "one\r\ntwo\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := run(t, interp, `import "io"
+print(io.exists(path))
+io.write(path, content)
+io.append(path, "three")
+print(io.exists(path))
+print(io.lines(path))
+print(#io.read(path))
+print(io.list(dir))`)
+	if err != "" {
+		t.Fatal(err)
+	}
+	want := "false\ntrue\n[\"one\", \"two\", \"three\"]\n14\n[\"notes.txt\"]\n"
+	if out != want {
+		t.Errorf("Output\n%s\nwant\n%s", out, want)
+	}
+
+	_, err = run(t, interp, `io.read(dir + "/missing")`)
+	if !strings.Contains(err, "no such file or directory") {
+		t.Errorf("Reading a missing file gave %q", err)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	interp := NewInterpreter()
+	program, err := interp.Parse("", `import "io"
+print(io.readLine())
+print(io.readLine())
+print(io.readAll())
+print(io.readLine())`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	interp.Stdout = &out
+	interp.Stdin = strings.NewReader("first\r\nsecond\nrest\nof it")
+	if _, err := interp.Run(program); err != nil {
+		t.Fatal(err)
+	}
+	want := "first\nsecond\nrest\nof it\n<nil>\n"
+	if out.String() != want {
+		t.Errorf("Output\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		src     string
+		without Capability
+		err     string
+	}{
+		{`import "io" io.read("x")`, FileRead, "Reading files is disabled"},
+		{`import "io" io.write("x", "")`, FileWrite, "Writing files is disabled"},
+		{`import "io" io.readLine()`, StdinRead, "Reading stdin is disabled"},
+		{`import "os" os.env("HOME")`, EnvRead, "Reading the environment is disabled"},
+		{`import "os" os.exit(1)`, ProcessExit, "Exiting is disabled"},
+		{`import "os" os.run("true", [])`, ProcessRun, "Running subprocesses is disabled"},
+	}
+
+	for _, test := range tests {
+		interp := NewInterpreter()
+		interp.Capabilities = AllCapabilities &^ test.without
+		_, err := run(t, interp, test.src)
+		if !strings.Contains(err, test.err) {
+			t.Errorf("%s: got error %q, want %q", test.src, err, test.err)
+		}
+	}
+
+	if got := (FileRead | EnvRead).String(); got != "Reading files, Reading the environment" {
+		t.Errorf("Capability names are %q", got)
+	}
+}
+
+func TestOSModule(t *testing.T) {
+	t.Setenv("LAGN_TEST_VALUE", "set")
+
+	interp := NewInterpreter()
+	interp.Args = []string{"a", "b"}
+	out, err := run(t, interp, `import "os"
+print(os.env("LAGN_TEST_VALUE"))
+print(os.env("LAGN_TEST_UNSET"))
+print(os.args())`)
+	if err != "" {
+		t.Fatal(err)
+	}
+	if want := "set\n<nil>\n[\"a\", \"b\"]\n"; out != want {
+		t.Errorf("Output\n%s\nwant\n%s", out, want)
+	}
+
+	// os.exit ends the run without exiting the process and passes through
+	// every enclosing call.
+	_, exitErr := interp.Eval(`fn f() os.exit(3) map([1], fn(x) f())`)
+	var exit *ExitError
+	if !errors.As(exitErr, &exit) || exit.Code != 3 {
+		t.Errorf("Got %v, want an ExitError with code 3", exitErr)
+	}
+}
+
+func TestOSRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	interp := NewInterpreter()
+	out, err := run(t, interp, `import "os"
+result := os.run("sh", ["-c", "echo out; echo err >&2; exit 2"])
+print(result["stdout"] + result["stderr"] + result["code"])`)
+	if err != "" {
+		t.Fatal(err)
+	}
+	if want := "out\nerr\n2\n"; out != want {
+		t.Errorf("Output\n%s\nwant\n%s", out, want)
+	}
+
+	_, err = run(t, interp, `os.run("`+filepath.Join(os.TempDir(), "lagn-missing-command")+`", [])`)
+	if err == "" {
+		t.Errorf("Running a missing command succeeded")
+	}
+}
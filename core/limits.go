@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultMaxCallDepth is the call depth a new Interpreter allows, deep
+// enough for ordinary recursion and well below where the Go stack of the
+// tree-walker would overflow.
+const DefaultMaxCallDepth = 10000
+
+// Limits bounds the resources a single run of an Interpreter may use. A
+// zero field means no limit.
+type Limits struct {
+	// MaxSteps caps the number of steps, where a step is one loop
+	// iteration or one function call.
+	MaxSteps int64
+	// MaxCallDepth caps the number of nested function calls.
+	MaxCallDepth int
+	// MaxArraySize caps the length of every array a program creates or
+	// grows.
+	MaxArraySize int
+}
+
+// CanceledError reports that the context of a run was canceled or its
+// deadline passed. It unwraps to the context's error.
+type CanceledError struct {
+	Span Span
+	Err  error
+}
+
+func (err *CanceledError) Error() string {
+	return fmt.Sprintf("[ERROR] Execution canceled: %v at %v", err.Err, err.Span)
+}
+
+func (err *CanceledError) Unwrap() error {
+	return err.Err
+}
+
+// StepLimitError reports that a run used up Limits.MaxSteps.
+type StepLimitError struct {
+	Span  Span
+	Limit int64
+}
+
+func (err *StepLimitError) Error() string {
+	return fmt.Sprintf("[ERROR] Step limit of %d exceeded at %v", err.Limit, err.Span)
+}
+
+// CallDepthError reports a call nested deeper than Limits.MaxCallDepth.
+type CallDepthError struct {
+	Span  Span
+	Limit int
+}
+
+func (err *CallDepthError) Error() string {
+	return fmt.Sprintf("[ERROR] Maximum call depth of %d exceeded at %v", err.Limit, err.Span)
+}
+
+// ArraySizeError reports an array that would grow beyond
+// Limits.MaxArraySize.
+type ArraySizeError struct {
+	Span  Span
+	Size  int64
+	Limit int
+}
+
+func (err *ArraySizeError) Error() string {
+	return fmt.Sprintf("[ERROR] Array of size %d exceeds the limit of %d at %v", err.Size, err.Limit, err.Span)
+}
+
+func (err *CanceledError) Location() Span  { return err.Span }
+func (err *StepLimitError) Location() Span { return err.Span }
+func (err *CallDepthError) Location() Span { return err.Span }
+func (err *ArraySizeError) Location() Span { return err.Span }
+
+// execution is the state of the current run of an Interpreter. Every
+// scope created while running shares the Interpreter's execution, so the
+// limits are reachable from any Environment.
+type execution struct {
+	interp *Interpreter
+	ctx    context.Context
+	limits Limits
+	steps  int64
+	// calls holds the call site of every active call, innermost last.
+	calls []Span
+}
+
+// Step counts one loop iteration or call at span and checks the context
+// and the step budget. Step, EnterCall, ExitCall and CheckArraySize are
+// called by the tree-walker and the builtins, and let another backend,
+// like the bytecode VM, count against the same limits.
+func (env *Environment) Step(span Span) error {
+	exec := env.exec
+	if exec == nil {
+		return nil
+	}
+
+	select {
+	case <-exec.ctx.Done():
+		return &CanceledError{Span: span, Err: exec.ctx.Err()}
+	default:
+	}
+
+	exec.steps++
+	if exec.limits.MaxSteps > 0 && exec.steps > exec.limits.MaxSteps {
+		return &StepLimitError{Span: span, Limit: exec.limits.MaxSteps}
+	}
+	return nil
+}
+
+// EnterCall counts a step and one more level of call depth. Every
+// successful EnterCall must be paired with ExitCall.
+func (env *Environment) EnterCall(span Span) error {
+	err := env.Step(span)
+	if err != nil || env.exec == nil {
+		return err
+	}
+
+	exec := env.exec
+	if exec.limits.MaxCallDepth > 0 && len(exec.calls) >= exec.limits.MaxCallDepth {
+		return &CallDepthError{Span: span, Limit: exec.limits.MaxCallDepth}
+	}
+	exec.calls = append(exec.calls, span)
+	return nil
+}
+
+func (env *Environment) ExitCall() {
+	if env.exec != nil {
+		env.exec.calls = env.exec.calls[:len(env.exec.calls)-1]
+	}
+}
+
+// callSite returns the span of the innermost active call, where errors
+// raised by Go functions without a location of their own are located.
+func (env *Environment) callSite() Span {
+	if env.exec == nil || len(env.exec.calls) == 0 {
+		return Span{}
+	}
+	return env.exec.calls[len(env.exec.calls)-1]
+}
+
+// CheckArraySize reports whether an array may have size elements.
+func (env *Environment) CheckArraySize(span Span, size int64) error {
+	if env.exec == nil || env.exec.limits.MaxArraySize <= 0 {
+		return nil
+	}
+	if size > int64(env.exec.limits.MaxArraySize) {
+		return &ArraySizeError{Span: span, Size: size, Limit: env.exec.limits.MaxArraySize}
+	}
+	return nil
+}
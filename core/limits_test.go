@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		limits Limits
+		err    string
+	}{
+		{
+			name:   "steps",
+			src:    `while (true) {}`,
+			limits: Limits{MaxSteps: 100},
+			err:    "[ERROR] Step limit of 100 exceeded at Line 1, Column 1",
+		},
+		{
+			name:   "steps within the budget",
+			src:    `for (i := 0; i < 10; i += 1) {}`,
+			limits: Limits{MaxSteps: 11},
+		},
+		{
+			name:   "call depth",
+			src:    `fn f(n) f(n + 1) f(0)`,
+			limits: Limits{MaxCallDepth: 50},
+			err:    "[ERROR] Maximum call depth of 50 exceeded at Line 1, Column 10",
+		},
+		{
+			name:   "array literal",
+			src:    `[1, 2, 3]`,
+			limits: Limits{MaxArraySize: 2},
+			err:    "[ERROR] Array of size 3 exceeds the limit of 2 at Line 1, Column 1",
+		},
+		{
+			name:   "array append",
+			src:    `a := [1, 2] a[#a] = 3`,
+			limits: Limits{MaxArraySize: 2},
+			err:    "[ERROR] Array of size 3 exceeds the limit of 2 at Line 1, Column 19",
+		},
+		{
+			name:   "array overwrite",
+			src:    `a := [1, 2] a[1] = 3`,
+			limits: Limits{MaxArraySize: 2},
+		},
+		{
+			name:   "array builtin",
+			src:    `a := [1, 2] push(a, 3)`,
+			limits: Limits{MaxArraySize: 2},
+			err:    "[ERROR] Array of size 3 exceeds the limit of 2 at Line 1, Column 17",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interp := NewInterpreter()
+			interp.Limits = test.limits
+			_, err := run(t, interp, test.src)
+			if err != test.err {
+				t.Errorf("Error %q, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestLimitsPerRun(t *testing.T) {
+	interp := NewInterpreter()
+	interp.Limits = Limits{MaxSteps: 15, MaxCallDepth: 20}
+
+	// Steps start over on every run.
+	for i := 0; i < 3; i++ {
+		if _, err := interp.Eval(`for (i := 0; i < 10; i += 1) {}`); err != nil {
+			t.Fatalf("Run %d: %v", i, err)
+		}
+	}
+
+	// A run that overflows the call depth leaves no calls behind.
+	if _, err := interp.Eval(`fn f(n) f(n + 1) f(0)`); err == nil {
+		t.Fatal("Unbounded recursion succeeded")
+	}
+	if _, err := interp.Eval(`fn g(n) { if (n > 0) g(n - 1) } g(10)`); err != nil {
+		t.Errorf("Recursion within the limit failed: %v", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	interp := NewInterpreter()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := interp.EvalContext(ctx, `while (true) {}`)
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) {
+		t.Fatalf("Got %v, want a CanceledError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v does not unwrap to the context's error", err)
+	}
+
+	// The context only applies to the run it was given to.
+	if _, err := interp.Eval(`1`); err != nil {
+		t.Errorf("Run after a canceled run: %v", err)
+	}
+}
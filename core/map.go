@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Map is the interpreter's dictionary value. It is always handled by
+// pointer so that every binding referring to it sees insertions and
+// deletions.
+type Map struct {
+	entries map[any]any
+}
+
+func NewMap() *Map {
+	return &Map{
+		entries: make(map[any]any),
+	}
+}
+
+// mapKey normalizes a key so that numerically equal keys collide, e.g.
+// m[1] and m[1.0] address the same entry.
+func mapKey(key any) (any, error) {
+	switch k := key.(type) {
+	case int64:
+		return k, nil
+	case float64:
+		if math.IsNaN(k) {
+			return nil, fmt.Errorf("Map key cannot be NaN")
+		}
+		if k == math.Trunc(k) && k >= math.MinInt64 && k < math.MaxInt64 {
+			return int64(k), nil
+		}
+		return k, nil
+	case string:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("Expected String, Int or Float as map key, got %s", TypeName(key))
+	}
+}
+
+func (m *Map) Get(key any) (any, bool, error) {
+	k, err := mapKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := m.entries[k]
+	return value, ok, nil
+}
+
+func (m *Map) Set(key any, value any) error {
+	k, err := mapKey(key)
+	if err != nil {
+		return err
+	}
+	m.entries[k] = value
+	return nil
+}
+
+func (m *Map) Delete(key any) error {
+	k, err := mapKey(key)
+	if err != nil {
+		return err
+	}
+	delete(m.entries, k)
+	return nil
+}
+
+func (m *Map) Len() int {
+	return len(m.entries)
+}
+
+// Keys returns the keys in a deterministic order: numbers ascending,
+// followed by strings in lexicographic order.
+func (m *Map) Keys() []any {
+	keys := make([]any, 0, len(m.entries))
+	for k := range m.entries {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+	return keys
+}
+
+func keyLess(a any, b any) bool {
+	as, aIsString := a.(string)
+	bs, bIsString := b.(string)
+	if aIsString || bIsString {
+		if aIsString && bIsString {
+			return as < bs
+		}
+		return bIsString
+	}
+
+	return toFloat(a) < toFloat(b)
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func (m *Map) String() string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range m.Keys() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(Inspect(k))
+		b.WriteString(": ")
+		b.WriteString(Inspect(m.entries[k]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Inspect formats a value nested inside a container, quoting strings so
+// that keys like "1" and 1 remain distinguishable.
+func Inspect(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,38 @@
+package core
+
+import "testing"
+
+func TestMaps(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "literal and index",
+			src:  `m := {"a": 1, 2: "two", 1.5: true} print(m["a"] + m[2]) print(m[1.5]) print(m["missing"])`,
+			out:  "1two\ntrue\n<nil>\n",
+		},
+		{
+			name: "deterministic order",
+			src:  `m := {"b": 1, "a": 2, 3: 0, 1: 0} print(m) print(keys(m))`,
+			out:  "{1: 0, 3: 0, \"a\": 2, \"b\": 1}\n[1, 3, \"a\", \"b\"]\n",
+		},
+		{
+			name: "builtins",
+			src:  `m := {"a": 1} m["b"] = 2 print(#m) print(has(m, "b")) delete(m, "b") print(has(m, "b")) empty := {:} print(#empty)`,
+			out:  "2\ntrue\nfalse\n0\n",
+		},
+		{
+			name: "nested",
+			src:  `m := {"k": [1, {"x": 2}]} print(m) print(m["k"][1]["x"])`,
+			out:  "{\"k\": [1, {\"x\": 2}]}\n2\n",
+		},
+		{
+			name: "unhashable key",
+			src:  `m := {:} m[[1]] = 2`,
+			err:  "[ERROR] Expected String, Int or Float as map key, got Array at Line 1, Column 17",
+		},
+		{
+			name: "builtin on a non-map",
+			src:  `keys(1)`,
+			err:  "[ERROR] Expected Map, got Int at Line 1, Column 5",
+		},
+	})
+}
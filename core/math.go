@@ -0,0 +1,273 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// mathModule returns the members of the math module.
+//
+// Functions that only make sense on Floats, like sqrt or sin, accept Ints
+// and always return a Float. abs, min, max, floor, ceil, round and trunc
+// keep Ints as Ints. int and float convert between the two
+// representations: int truncates toward zero and rejects NaN, infinities
+// and Floats outside the Int range, round rounds halfway cases away from
+// zero.
+func mathModule() map[string]any {
+	return map[string]any{
+		"pi":  math.Pi,
+		"e":   math.E,
+		"inf": math.Inf(1),
+		"nan": math.NaN(),
+
+		"sqrt":  floatFunc(math.Sqrt),
+		"cbrt":  floatFunc(math.Cbrt),
+		"exp":   floatFunc(math.Exp),
+		"log":   floatFunc(math.Log),
+		"log2":  floatFunc(math.Log2),
+		"log10": floatFunc(math.Log10),
+		"sin":   floatFunc(math.Sin),
+		"cos":   floatFunc(math.Cos),
+		"tan":   floatFunc(math.Tan),
+		"asin":  floatFunc(math.Asin),
+		"acos":  floatFunc(math.Acos),
+		"atan":  floatFunc(math.Atan),
+		"atan2": floatFunc2(math.Atan2),
+		"hypot": floatFunc2(math.Hypot),
+
+		"floor": roundingFunc(math.Floor),
+		"ceil":  roundingFunc(math.Ceil),
+		"round": roundingFunc(math.Round),
+		"trunc": roundingFunc(math.Trunc),
+
+		"abs": Function{
+			Arity: 1,
+			Call: func(_ *Environment, args []any) (any, error) {
+				switch n := args[0].(type) {
+				case int64:
+					if n == math.MinInt64 {
+						return nil, fmt.Errorf("abs(%d) overflows Int", n)
+					}
+					if n < 0 {
+						return -n, nil
+					}
+					return n, nil
+				case float64:
+					return math.Abs(n), nil
+				}
+				return nil, expectedNumber(args[0])
+			},
+		},
+		"min": Function{
+			Arity: 2,
+			Call: func(_ *Environment, args []any) (any, error) {
+				return pick(args[0], args[1], LESS)
+			},
+		},
+		"max": Function{
+			Arity: 2,
+			Call: func(_ *Environment, args []any) (any, error) {
+				return pick(args[0], args[1], GREATER)
+			},
+		},
+		"pow": Function{
+			Arity: 2,
+			Call: func(_ *Environment, args []any) (any, error) {
+				base, baseIsInt := args[0].(int64)
+				exponent, exponentIsInt := args[1].(int64)
+				if baseIsInt && exponentIsInt && exponent >= 0 {
+					return intPow(base, exponent), nil
+				}
+
+				x, err := numberArg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				y, err := numberArg(args[1])
+				if err != nil {
+					return nil, err
+				}
+				return math.Pow(x, y), nil
+			},
+		},
+
+		"gcd": intFunc2(gcd),
+		"lcm": intFunc2(func(a int64, b int64) int64 {
+			if a == 0 || b == 0 {
+				return 0
+			}
+			l := a / gcd(a, b) * b
+			if l < 0 {
+				return -l
+			}
+			return l
+		}),
+
+		"int": Function{
+			Arity: 1,
+			Call: func(_ *Environment, args []any) (any, error) {
+				switch n := args[0].(type) {
+				case int64:
+					return n, nil
+				case float64:
+					return floatToInt(n)
+				}
+				return nil, expectedNumber(args[0])
+			},
+		},
+		"float": floatFunc(func(x float64) float64 {
+			return x
+		}),
+		"isNaN": predicate(math.IsNaN),
+		"isInf": predicate(func(x float64) bool {
+			return math.IsInf(x, 0)
+		}),
+	}
+}
+
+func expectedNumber(v any) error {
+	return fmt.Errorf("Expected Int or Float, got %s", TypeName(v))
+}
+
+// numberArg reads an Int or Float argument as a float64.
+func numberArg(v any) (float64, error) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	}
+	return 0, expectedNumber(v)
+}
+
+func floatFunc(f func(float64) float64) Function {
+	return Function{
+		Arity: 1,
+		Call: func(_ *Environment, args []any) (any, error) {
+			x, err := numberArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return f(x), nil
+		},
+	}
+}
+
+func floatFunc2(f func(float64, float64) float64) Function {
+	return Function{
+		Arity: 2,
+		Call: func(_ *Environment, args []any) (any, error) {
+			x, err := numberArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			y, err := numberArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+			return f(x, y), nil
+		},
+	}
+}
+
+// roundingFunc applies f to Floats and returns Ints unchanged, since they
+// are already whole.
+func roundingFunc(f func(float64) float64) Function {
+	return Function{
+		Arity: 1,
+		Call: func(_ *Environment, args []any) (any, error) {
+			switch n := args[0].(type) {
+			case int64:
+				return n, nil
+			case float64:
+				return f(n), nil
+			}
+			return nil, expectedNumber(args[0])
+		},
+	}
+}
+
+func predicate(f func(float64) bool) Function {
+	return Function{
+		Arity: 1,
+		Call: func(_ *Environment, args []any) (any, error) {
+			x, err := numberArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return f(x), nil
+		},
+	}
+}
+
+func intFunc2(f func(int64, int64) int64) Function {
+	return Function{
+		Arity: 2,
+		Call: func(_ *Environment, args []any) (any, error) {
+			a, ok := args[0].(int64)
+			if !ok {
+				return nil, fmt.Errorf("Expected Int, got %s", TypeName(args[0]))
+			}
+			b, ok := args[1].(int64)
+			if !ok {
+				return nil, fmt.Errorf("Expected Int, got %s", TypeName(args[1]))
+			}
+			return f(a, b), nil
+		},
+	}
+}
+
+// pick returns whichever of a and b wins the comparison, keeping its
+// type.
+func pick(a any, b any, operator TokenType) (any, error) {
+	if _, err := numberArg(a); err != nil {
+		return nil, err
+	}
+	if _, err := numberArg(b); err != nil {
+		return nil, err
+	}
+
+	better, err := compare(operator, b, a)
+	if err != nil {
+		return nil, err
+	}
+	if better.(bool) {
+		return b, nil
+	}
+	return a, nil
+}
+
+// intPow computes base**exponent by squaring, wrapping around on overflow
+// like every other Int operation.
+func intPow(base int64, exponent int64) int64 {
+	result := int64(1)
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+	return result
+}
+
+func gcd(a int64, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func floatToInt(x float64) (int64, error) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, fmt.Errorf("Cannot convert %v to Int", x)
+	}
+	x = math.Trunc(x)
+	if x < math.MinInt64 || x >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v is out of the Int range", x)
+	}
+	return int64(x), nil
+}
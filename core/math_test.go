@@ -0,0 +1,71 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+// valueTest is a lagn expression with the value, or the error, it should
+// evaluate to.
+type valueTest struct {
+	src  string
+	want any
+	err  string
+}
+
+func evalValues(t *testing.T, prelude string, tests []valueTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		got, err := NewInterpreter().Eval(prelude + " " + test.src)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: got error %v, want %q", test.src, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		if f, ok := test.want.(float64); ok && math.IsNaN(f) {
+			if got, ok := got.(float64); !ok || !math.IsNaN(got) {
+				t.Errorf("%s = %v, want NaN", test.src, got)
+			}
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s = %v (%s), want %v (%s)", test.src, got, TypeName(got), test.want, TypeName(test.want))
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	evalValues(t, `import "math"`, []valueTest{
+		{src: `math.pi`, want: math.Pi},
+		{src: `math.nan`, want: math.NaN()},
+		{src: `math.sqrt(16)`, want: 4.0},
+		{src: `math.atan2(1, 1)`, want: math.Pi / 4},
+		{src: `math.hypot(3, 4)`, want: 5.0},
+		{src: `math.floor(-2.5)`, want: -3.0},
+		{src: `math.round(2)`, want: int64(2)},
+		{src: `math.abs(-3)`, want: int64(3)},
+		{src: `math.abs(-1.5)`, want: 1.5},
+		{src: `math.min(2, 1.5)`, want: 1.5},
+		{src: `math.max(2, 1.5)`, want: int64(2)},
+		{src: `math.pow(2, 10)`, want: int64(1024)},
+		{src: `math.pow(2, -1)`, want: 0.5},
+		{src: `math.pow(4, 0.5)`, want: 2.0},
+		{src: `math.gcd(-12, 18)`, want: int64(6)},
+		{src: `math.lcm(4, 6)`, want: int64(12)},
+		{src: `math.int(-2.7)`, want: int64(-2)},
+		{src: `math.float(3)`, want: 3.0},
+		{src: `math.isNaN(math.nan)`, want: true},
+		{src: `math.isInf(-math.inf)`, want: true},
+		{src: `math.sqrt("4")`, err: "[ERROR] Expected Int or Float, got String at Line 1, Column 24"},
+		{src: `math.gcd(1.5, 2)`, err: "[ERROR] Expected Int, got Float at Line 1, Column 23"},
+		{src: `math.abs(-9223372036854775807 - 1)`, err: "[ERROR] abs(-9223372036854775808) overflows Int at Line 1, Column 23"},
+		{src: `math.int(math.nan)`, err: "[ERROR] Cannot convert NaN to Int at Line 1, Column 23"},
+		{src: `math.int(math.pow(10.0, 30))`, err: "[ERROR] 1e+30 is out of the Int range at Line 1, Column 23"},
+	})
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Module is the namespace of an imported file. Its members are the
+// file's top-level declarations and are read with the '.' operator.
+type Module struct {
+	Name string
+	Path string
+	env  *Environment
+}
+
+func (m *Module) Get(name string) (any, bool) {
+	value, ok := m.env.values[name]
+	return value, ok
+}
+
+// Members returns the names of every member in lexicographic order.
+func (m *Module) Members() []string {
+	return m.env.Names()
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("<module %s>", m.Name)
+}
+
+// moduleName derives the default binding of an import from its path, e.g.
+// "lib/strings.lagn" is bound to strings.
+func moduleName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func isIdentifier(name string) bool {
+	for i, c := range name {
+		if !unicode.IsLetter(c) && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	_, keyword := KEYWORDS[name]
+	return name != "" && !keyword
+}
+
+// Import loads the module at path on behalf of the file importer, see
+// Interpreter.Import.
+func (env *Environment) Import(path string, importer string) (*Module, error) {
+	if env.exec == nil || env.exec.interp == nil {
+		return nil, fmt.Errorf("Cannot import %q outside of an Interpreter", path)
+	}
+	return env.exec.interp.Import(path, importer)
+}
+
+// Import returns the module at path, evaluating it the first time it is
+// imported. Modules registered with RegisterModule take precedence over
+// files. Relative paths are looked up next to the importing file, or in
+// the working directory when importer is empty, and then in every
+// directory of SearchPath. The extension ".lagn" may be left out.
+func (interp *Interpreter) Import(path string, importer string) (*Module, error) {
+	if module, ok := interp.natives[path]; ok {
+		return module, nil
+	}
+
+	file, err := interp.findModule(path, importer)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if module, ok := interp.modules[key]; ok {
+		return module, nil
+	}
+
+	if i := slices.Index(interp.loading, key); i >= 0 {
+		var cycle []string
+		for _, loading := range interp.loading[i:] {
+			cycle = append(cycle, filepath.Base(loading))
+		}
+		cycle = append(cycle, filepath.Base(key))
+		return nil, fmt.Errorf("Import cycle: %s", strings.Join(cycle, " -> "))
+	}
+	interp.loading = append(interp.loading, key)
+	defer func() {
+		interp.loading = interp.loading[:len(interp.loading)-1]
+	}()
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	interp.sources[file] = string(content)
+
+	module := &Module{
+		Name: moduleName(file),
+		Path: file,
+		env:  NewEnvironment(interp.builtins),
+	}
+	program, err := interp.parse(file, string(content), module.env)
+	if err != nil {
+		return nil, err
+	}
+	for _, expr := range program {
+		_, err := expr.Interpret(module.env)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	interp.modules[key] = module
+	return module, nil
+}
+
+// RegisterModule makes members importable as the module name. Go
+// functions are wrapped like in RegisterFunc and every other value is
+// converted with ToValue.
+func (interp *Interpreter) RegisterModule(name string, members map[string]any) error {
+	module := &Module{
+		Name: name,
+		Path: name,
+		env:  NewEnvironment(nil),
+	}
+	for member, value := range members {
+		var err error
+		if _, ok := value.(Function); !ok && reflect.ValueOf(value).Kind() == reflect.Func {
+			value, err = wrapFunc(name+"."+member, value)
+		} else {
+			value, err = ToValue(value)
+		}
+		if err != nil {
+			return err
+		}
+		module.env.declareVar(member, value)
+	}
+
+	interp.natives[name] = module
+	return nil
+}
+
+// NativeModule returns the module registered as name with RegisterModule.
+// Unlike Import it never runs a file.
+func (interp *Interpreter) NativeModule(name string) (*Module, bool) {
+	module, ok := interp.natives[name]
+	return module, ok
+}
+
+func (interp *Interpreter) findModule(path string, importer string) (string, error) {
+	var dirs []string
+	if filepath.IsAbs(path) {
+		dirs = []string{""}
+	} else {
+		dirs = append([]string{filepath.Dir(importer)}, interp.SearchPath...)
+	}
+
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, path)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+		if filepath.Ext(path) == "" {
+			if _, err := os.Stat(candidate + ".lagn"); err == nil {
+				return candidate + ".lagn", nil
+			}
+		}
+	}
+	return "", fmt.Errorf("Cannot find module %q", path)
+}
+
+// FormatError is like the package-level FormatError, but renders errors
+// located in an imported module with that module's own source.
+func (interp *Interpreter) FormatError(err error, source string) string {
+	return formatError(err, func(span Span) string {
+		if module, ok := interp.sources[span.File]; ok && span.File != "" {
+			return module
+		}
+		return source
+	})
+}
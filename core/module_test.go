@@ -0,0 +1,134 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files, keyed by their path relative to a new
+// temporary directory, and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.lagn": `import "lib/counter"
+import again "lib/counter.lagn"
+import "shared"
+print(counter.next() + again.next())
+print(shared.name)`,
+		"lib/counter.lagn": `print("loading")
+n := 0
+fn next() {
+  n += 1
+  return n
+}`,
+		"path/shared.lagn": `name := "from the search path"`,
+	})
+
+	interp := NewInterpreter()
+	interp.SearchPath = []string{filepath.Join(dir, "path")}
+	out, err := runFile(t, interp, filepath.Join(dir, "main.lagn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both imports share one module, which only runs once.
+	want := "loading\n3\nfrom the search path\n"
+	if out != want {
+		t.Errorf("Output\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.lagn":      `import "b"`,
+		"b.lagn":      `import "a"`,
+		"broken.lagn": "x := 1\ny := x + true",
+		"member.lagn": `import "broken"`,
+	})
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"a.lagn", "Import cycle: b.lagn -> a.lagn -> b.lagn"},
+		{"member.lagn", "y := x + true"},
+	}
+	for _, test := range tests {
+		interp := NewInterpreter()
+		path := filepath.Join(dir, test.file)
+		_, err := runFile(t, interp, path)
+		if err == nil {
+			t.Errorf("%s: succeeded", test.file)
+			continue
+		}
+		// Errors inside a module are shown with the module's source.
+		content, _ := os.ReadFile(path)
+		if formatted := interp.FormatError(err, string(content)); !strings.Contains(formatted, test.want) {
+			t.Errorf("%s: got\n%s\nwant it to contain %q", test.file, formatted, test.want)
+		}
+	}
+
+	runPrograms(t, []programTest{
+		{
+			name: "missing module",
+			src:  `import "nothere"`,
+			err:  "[ERROR] Cannot find module \"nothere\" at Line 1, Column 8",
+		},
+		{
+			name: "missing member",
+			src:  `import "math" math.nope`,
+			err:  "[ERROR] Module math has no member nope at Line 1, Column 20",
+		},
+		{
+			name: "not a module",
+			src:  `x := 1 x.y`,
+			err:  "[ERROR] Expected Module before '.', got Int at Line 1, Column 10",
+		},
+	})
+}
+
+func TestRegisterModule(t *testing.T) {
+	interp := NewInterpreter()
+	err := interp.RegisterModule("host", map[string]any{
+		"version": 2,
+		"greet":   func(name string) string { return "hi " + name },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := interp.Eval(`import "host" host.greet("lagn") + host.version`)
+	if err != nil || got != "hi lagn2" {
+		t.Errorf("Got %v, %v, want hi lagn2", got, err)
+	}
+	module, ok := interp.NativeModule("host")
+	if !ok || strings.Join(module.Members(), ",") != "greet,version" {
+		t.Errorf("NativeModule is %v, %v", module, ok)
+	}
+}
+
+// runFile runs the file at path like EvalFile and returns what it printed.
+func runFile(t *testing.T, interp *Interpreter, path string) (string, error) {
+	t.Helper()
+
+	var out strings.Builder
+	interp.Stdout = &out
+	_, err := interp.EvalFile(path)
+	return out.String(), err
+}
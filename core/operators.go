@@ -0,0 +1,332 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+var compoundOperators = map[TokenType]TokenType{
+	PLUS_EQ:    PLUS,
+	MINUS_EQ:   MINUS,
+	STAR_EQ:    STAR,
+	SLASH_EQ:   SLASH,
+	PERCENT_EQ: PERCENT,
+}
+
+// CompoundOperator returns the binary operator a compound assignment
+// operator such as += applies before storing the result.
+func CompoundOperator(assignment TokenType) (TokenType, bool) {
+	op, ok := compoundOperators[assignment]
+	return op, ok
+}
+
+// BinaryOp applies a binary operator to two evaluated operands.
+//
+//	== !=        any values, Ints and Floats compare numerically
+//	< <= > >=    Int/Float numerically, String lexicographically
+//	+            Int/Float arithmetic, String concatenation with any value
+//	- * / %      Int/Float arithmetic, Int op Int stays Int
+//	& |          Int bitwise, Bool logical without short-circuit
+//
+// The short-circuiting && and || are evaluated by LogicalExpr instead.
+//
+// Mixing an Int with a Float promotes the Int to Float. Integer division
+// or modulo by zero is an error, Float division follows IEEE 754.
+func BinaryOp(operator TokenType, l any, r any) (any, error) {
+	switch operator {
+	case PLUS:
+		return add(l, r)
+	case MINUS, STAR, SLASH, PERCENT:
+		return arithmetic(operator, l, r)
+	case EQUAL_EQ:
+		return valuesEqual(l, r), nil
+	case BANG_EQ:
+		return !valuesEqual(l, r), nil
+	case GREATER, GREATER_EQ, LESS, LESS_EQ:
+		return compare(operator, l, r)
+	case AMP, BAR:
+		return bitwise(operator, l, r)
+	default:
+		return nil, fmt.Errorf("Invalid Binary Operator %v", operator)
+	}
+}
+
+// UnaryOp applies a prefix operator to an evaluated operand.
+func UnaryOp(operator TokenType, v any) (any, error) {
+	switch operator {
+	case BANG:
+		if r, ok := v.(bool); ok {
+			return !r, nil
+		}
+		return nil, fmt.Errorf("Expected Bool, got %s", TypeName(v))
+	case MINUS:
+		if r, ok := v.(int64); ok {
+			return -r, nil
+		}
+		if r, ok := v.(float64); ok {
+			return -r, nil
+		}
+		return nil, fmt.Errorf("Expected Int or Float, got %s", TypeName(v))
+	case HASHTAG:
+		if r, ok := v.(*Array); ok {
+			return int64(r.Len()), nil
+		}
+		if r, ok := v.(*Map); ok {
+			return int64(r.Len()), nil
+		}
+		if r, ok := v.(string); ok {
+			return int64(utf8.RuneCountInString(r)), nil
+		}
+		return nil, fmt.Errorf("Expected Array, Map or String, got %s", TypeName(v))
+	default:
+		return nil, fmt.Errorf("Invalid Unary Operator %v", operator)
+	}
+}
+
+// TypeName returns the name of a value's type as lagn users know it.
+func TypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "Nil"
+	case int64:
+		return "Int"
+	case float64:
+		return "Float"
+	case string:
+		return "String"
+	case bool:
+		return "Bool"
+	case *Array:
+		return "Array"
+	case *Map:
+		return "Map"
+	case Function:
+		return "Function"
+	case *Module:
+		return "Module"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func unsupportedOperands(operator TokenType, l any, r any) error {
+	return fmt.Errorf("Unsupported operand types for %v: %s and %s", operatorSymbol(operator), TypeName(l), TypeName(r))
+}
+
+func operatorSymbol(tokenType TokenType) string {
+	switch tokenType {
+	case PLUS:
+		return "+"
+	case MINUS:
+		return "-"
+	case STAR:
+		return "*"
+	case SLASH:
+		return "/"
+	case PERCENT:
+		return "%"
+	case GREATER:
+		return ">"
+	case GREATER_EQ:
+		return ">="
+	case LESS:
+		return "<"
+	case LESS_EQ:
+		return "<="
+	case AMP:
+		return "&"
+	case AMP_AMP:
+		return "&&"
+	case BAR:
+		return "|"
+	case BAR_BAR:
+		return "||"
+	case EQUAL_EQ:
+		return "=="
+	case BANG_EQ:
+		return "!="
+	case BANG:
+		return "!"
+	case HASHTAG:
+		return "#"
+	}
+	return tokenType.String()
+}
+
+// promote converts two numeric operands to a common type: both Int when
+// both are Int, both Float otherwise.
+func promote(l any, r any) (any, any, bool) {
+	switch left := l.(type) {
+	case int64:
+		switch right := r.(type) {
+		case int64:
+			return left, right, true
+		case float64:
+			return float64(left), right, true
+		}
+	case float64:
+		switch right := r.(type) {
+		case int64:
+			return left, float64(right), true
+		case float64:
+			return left, right, true
+		}
+	}
+	return nil, nil, false
+}
+
+func add(l any, r any) (any, error) {
+	if ls, ok := l.(string); ok {
+		return ls + stringify(r), nil
+	}
+	if rs, ok := r.(string); ok {
+		return stringify(l) + rs, nil
+	}
+
+	a, b, ok := promote(l, r)
+	if !ok {
+		return nil, fmt.Errorf("Unsupported operand types for +: %s and %s", TypeName(l), TypeName(r))
+	}
+	if a, ok := a.(int64); ok {
+		return a + b.(int64), nil
+	}
+	return a.(float64) + b.(float64), nil
+}
+
+// stringify formats a value the same way print does.
+func stringify(v any) string {
+	return fmt.Sprint(v)
+}
+
+func arithmetic(operator TokenType, l any, r any) (any, error) {
+	a, b, ok := promote(l, r)
+	if !ok {
+		return nil, unsupportedOperands(operator, l, r)
+	}
+
+	if a, ok := a.(int64); ok {
+		b := b.(int64)
+		switch operator {
+		case MINUS:
+			return a - b, nil
+		case STAR:
+			return a * b, nil
+		case SLASH:
+			if b == 0 {
+				return nil, fmt.Errorf("Integer division by zero")
+			}
+			return a / b, nil
+		case PERCENT:
+			if b == 0 {
+				return nil, fmt.Errorf("Integer modulo by zero")
+			}
+			return a % b, nil
+		}
+	}
+
+	x, y := a.(float64), b.(float64)
+	switch operator {
+	case MINUS:
+		return x - y, nil
+	case STAR:
+		return x * y, nil
+	case SLASH:
+		return x / y, nil
+	case PERCENT:
+		return math.Mod(x, y), nil
+	}
+	return nil, fmt.Errorf("Invalid Binary Operator %v", operator)
+}
+
+func compare(operator TokenType, l any, r any) (any, error) {
+	var cmp int
+	if ls, ok := l.(string); ok {
+		rs, ok := r.(string)
+		if !ok {
+			return nil, unsupportedOperands(operator, l, r)
+		}
+		switch {
+		case ls < rs:
+			cmp = -1
+		case ls > rs:
+			cmp = 1
+		}
+	} else {
+		a, b, ok := promote(l, r)
+		if !ok {
+			return nil, unsupportedOperands(operator, l, r)
+		}
+		switch a := a.(type) {
+		case int64:
+			b := b.(int64)
+			switch {
+			case a < b:
+				cmp = -1
+			case a > b:
+				cmp = 1
+			}
+		case float64:
+			x, y := a, b.(float64)
+			// Every ordered comparison involving NaN is false.
+			if math.IsNaN(x) || math.IsNaN(y) {
+				return false, nil
+			}
+			switch {
+			case x < y:
+				cmp = -1
+			case x > y:
+				cmp = 1
+			}
+		}
+	}
+
+	switch operator {
+	case GREATER:
+		return cmp > 0, nil
+	case GREATER_EQ:
+		return cmp >= 0, nil
+	case LESS:
+		return cmp < 0, nil
+	default:
+		return cmp <= 0, nil
+	}
+}
+
+func bitwise(operator TokenType, l any, r any) (any, error) {
+	switch left := l.(type) {
+	case int64:
+		if right, ok := r.(int64); ok {
+			if operator == AMP {
+				return left & right, nil
+			}
+			return left | right, nil
+		}
+	case bool:
+		if right, ok := r.(bool); ok {
+			if operator == AMP {
+				return left && right, nil
+			}
+			return left || right, nil
+		}
+	}
+	return nil, unsupportedOperands(operator, l, r)
+}
+
+// valuesEqual reports whether two values are equal. Numbers compare by
+// value across Int and Float, Arrays and Maps by identity. Functions
+// cannot be compared in Go and are never equal.
+func valuesEqual(l any, r any) bool {
+	if a, b, ok := promote(l, r); ok {
+		return a == b
+	}
+	switch l.(type) {
+	case Function:
+		return false
+	}
+	switch r.(type) {
+	case Function:
+		return false
+	}
+	return l == r
+}
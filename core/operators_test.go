@@ -0,0 +1,157 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBinaryOp(t *testing.T) {
+	arr := NewArray(nil)
+	tests := []struct {
+		operator TokenType
+		l, r     any
+		want     any
+	}{
+		{PLUS, int64(1), int64(2), int64(3)},
+		{PLUS, int64(1), 0.5, 1.5},
+		{PLUS, "n = ", int64(1), "n = 1"},
+		{PLUS, 2.5, "!", "2.5!"},
+		{MINUS, int64(1), 2.5, -1.5},
+		{STAR, 1.5, int64(2), 3.0},
+		{SLASH, int64(7), int64(2), int64(3)},
+		{SLASH, int64(7), 2.0, 3.5},
+		{SLASH, 1.0, 0.0, math.Inf(1)},
+		{PERCENT, int64(-7), int64(3), int64(-1)},
+		{PERCENT, 5.5, int64(2), 1.5},
+		{EQUAL_EQ, int64(2), 2.0, true},
+		{EQUAL_EQ, "a", int64(1), false},
+		{EQUAL_EQ, arr, arr, true},
+		{EQUAL_EQ, arr, NewArray(nil), false},
+		{EQUAL_EQ, nil, nil, true},
+		{BANG_EQ, "x", "y", true},
+		{LESS, int64(1), 2.5, true},
+		{GREATER_EQ, "b", "a", true},
+		{LESS, math.NaN(), 1.0, false},
+		{AMP, int64(6), int64(3), int64(2)},
+		{BAR, false, true, true},
+	}
+
+	for _, test := range tests {
+		got, err := BinaryOp(test.operator, test.l, test.r)
+		if err != nil {
+			t.Errorf("%v %v %v: %v", test.l, operatorSymbol(test.operator), test.r, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%v %v %v = %v (%s), want %v (%s)", test.l, operatorSymbol(test.operator), test.r, got, TypeName(got), test.want, TypeName(test.want))
+		}
+	}
+}
+
+func TestBinaryOpErrors(t *testing.T) {
+	tests := []struct {
+		operator TokenType
+		l, r     any
+		want     string
+	}{
+		{PLUS, true, int64(1), "Unsupported operand types for +: Bool and Int"},
+		{MINUS, "a", "b", "Unsupported operand types for -: String and String"},
+		{SLASH, int64(1), int64(0), "Integer division by zero"},
+		{PERCENT, int64(1), int64(0), "Integer modulo by zero"},
+		{LESS, "a", int64(1), "Unsupported operand types for <: String and Int"},
+		{AMP, int64(1), true, "Unsupported operand types for &: Int and Bool"},
+		{STAR, NewArray(nil), nil, "Unsupported operand types for *: Array and Nil"},
+	}
+
+	for _, test := range tests {
+		_, err := BinaryOp(test.operator, test.l, test.r)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%v %v %v: got error %v, want %q", test.l, operatorSymbol(test.operator), test.r, err, test.want)
+		}
+	}
+}
+
+func TestUnaryOp(t *testing.T) {
+	tests := []struct {
+		operator TokenType
+		v        any
+		want     any
+		err      string
+	}{
+		{BANG, true, false, ""},
+		{MINUS, int64(2), int64(-2), ""},
+		{MINUS, 1.5, -1.5, ""},
+		{HASHTAG, "héllo", int64(5), ""},
+		{BANG, int64(1), nil, "Expected Bool, got Int"},
+		{MINUS, "x", nil, "Expected Int or Float, got String"},
+		{HASHTAG, nil, nil, "Expected Array, Map or String, got Nil"},
+	}
+
+	for _, test := range tests {
+		got, err := UnaryOp(test.operator, test.v)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v%v: got error %v, want %q", operatorSymbol(test.operator), test.v, err, test.err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%v%v = %v, %v, want %v", operatorSymbol(test.operator), test.v, got, err, test.want)
+		}
+	}
+}
+
+func TestOperatorErrorLocation(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "binary",
+			src:  `x := 1 + "a" - 2`,
+			err:  "[ERROR] Unsupported operand types for -: String and Int at Line 1, Column 14",
+		},
+		{
+			name: "compound assignment",
+			src:  `x := true x += 1`,
+			err:  "[ERROR] Unsupported operand types for +: Bool and Int at Line 1, Column 13",
+		},
+	})
+}
+
+func TestShortCircuit(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "skips the right operand",
+			src:  `fn loud(v) { print(v) return v } print(loud(false) && loud(true)) print(loud(true) || loud(false))`,
+			out:  "false\nfalse\ntrue\ntrue\n",
+		},
+		{
+			name: "evaluates the right operand",
+			src:  `fn loud(v) { print(v) return v } print(loud(true) && loud(false)) print(loud(false) || loud(true))`,
+			out:  "true\nfalse\nfalse\nfalse\ntrue\ntrue\n",
+		},
+		{
+			name: "guards an index",
+			src:  `a := [] print(#a > 0 && a[0] == 1)`,
+			out:  "false\n",
+		},
+		{
+			name: "precedence",
+			src:  `print(true || false && false) print((true || false) && false)`,
+			out:  "true\nfalse\n",
+		},
+		{
+			name: "skipped operand is not checked",
+			src:  `print(false && 1) print(true || 1)`,
+			out:  "false\ntrue\n",
+		},
+		{
+			name: "left operand type",
+			src:  `1 && true`,
+			err:  "[ERROR] Expected Bool as left operand of &&, got Int at Line 1, Column 3",
+		},
+		{
+			name: "right operand type",
+			src:  `false || "x"`,
+			err:  "[ERROR] Expected Bool as right operand of ||, got String at Line 1, Column 7",
+		},
+	})
+}
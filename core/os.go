@@ -0,0 +1,76 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// ExitError ends a run of a program that called os.exit. The Interpreter
+// never exits the process itself, the host decides what to do with Code.
+type ExitError struct {
+	Code int
+}
+
+func (err *ExitError) Error() string {
+	return fmt.Sprintf("Exited with code %d", err.Code)
+}
+
+// IsExit reports whether err, or any error it wraps, is an ExitError.
+func IsExit(err error) bool {
+	var exit *ExitError
+	return errors.As(err, &exit)
+}
+
+// osModule returns the members of the os module.
+func osModule(interp *Interpreter) map[string]any {
+	return map[string]any{
+		// env returns the value of an environment variable, or nil if it
+		// is not set.
+		"env": func(name string) (any, error) {
+			if err := interp.require(EnvRead); err != nil {
+				return nil, err
+			}
+			if value, ok := os.LookupEnv(name); ok {
+				return value, nil
+			}
+			return nil, nil
+		},
+		"args": func() []string {
+			return append([]string{}, interp.Args...)
+		},
+		"exit": func(code int) error {
+			if err := interp.require(ProcessExit); err != nil {
+				return err
+			}
+			return &ExitError{Code: code}
+		},
+
+		// run starts command with args, waits for it and returns a Map
+		// with its "stdout", "stderr" and exit "code". A command that
+		// fails is not an error, one that cannot be started is.
+		"run": func(command string, args []string) (map[string]any, error) {
+			if err := interp.require(ProcessRun); err != nil {
+				return nil, err
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd := exec.CommandContext(interp.exec.ctx, command, args...)
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				return nil, err
+			}
+			return map[string]any{
+				"stdout": stdout.String(),
+				"stderr": stderr.String(),
+				"code":   cmd.ProcessState.ExitCode(),
+			}, nil
+		},
+	}
+}
@@ -6,8 +6,12 @@ import (
 )
 
 type Parser struct {
-	tokens  []Token
-	current int
+	tokens    []Token
+	current   int
+	fnDepth   int
+	loopDepth int
+	labels    []string
+	errors    ErrorList
 }
 
 func CreateParser(tokens []Token) Parser {
@@ -17,21 +21,82 @@ func CreateParser(tokens []Token) Parser {
 	}
 }
 
+// Parse parses the whole token stream. Syntax errors do not stop the parse:
+// each one is recorded and the parser skips ahead to the next statement, so
+// the returned program is a best-effort tree of everything that parsed and
+// the error, if any, is an ErrorList holding every diagnostic.
 func (parser *Parser) Parse() ([]Expr, error) {
 	var program []Expr = []Expr{}
 
 	for !parser.isAtEnd() {
-		expr, err := parser.expression()
+		start := parser.current
+		var expr Expr
+		var err error
+		if parser.match(IMPORT) {
+			expr, err = parser.importStmt()
+		} else {
+			expr, err = parser.expression()
+		}
 		if err != nil {
-			return nil, err
+			parser.recover(err, start)
+			continue
 		}
 
 		program = append(program, expr)
+		parser.endStatement()
 	}
 
+	if len(parser.errors) > 0 {
+		return program, parser.errors
+	}
 	return program, nil
 }
 
+// endStatement skips the ';' that may end a statement. It is only needed
+// where the next statement would otherwise continue this one, like one
+// starting with '(' after a call.
+func (parser *Parser) endStatement() {
+	parser.match(SEMI)
+}
+
+// recover records err and synchronizes, making sure the parser moves past
+// the statement that started at start even when the offending token is a
+// boundary itself, like a stray '}'.
+func (parser *Parser) recover(err error, start int) {
+	parser.errors = append(parser.errors, err)
+	line := parser.tokens[parser.current].Line
+	if span, ok := ErrorSpan(err); ok {
+		line = span.Line
+	}
+	parser.synchronize(line)
+	if parser.current == start {
+		parser.advance()
+	}
+}
+
+// synchronize discards tokens until it reaches a likely statement boundary:
+// right after a ';', before a '}' or a statement keyword, or at the first
+// token on a later line than the error.
+func (parser *Parser) synchronize(line int) {
+	start := parser.current
+	for !parser.isAtEnd() {
+		if parser.current > start && parser.tokens[parser.current-1].Type == SEMI {
+			return
+		}
+
+		token := parser.tokens[parser.current]
+		switch token.Type {
+		case RIGHT_BRACE, IF, WHILE, FOR, FUNCTION, RETURN, BREAK, CONTINUE, IMPORT:
+			return
+		}
+		if token.Line > line {
+			return
+		}
+
+		parser.advance()
+	}
+}
+
 func (parser *Parser) expression() (Expr, error) {
 	return parser.controlFlow()
 }
@@ -40,20 +105,60 @@ func (parser *Parser) controlFlow() (Expr, error) {
 	if parser.match(IF) {
 		return parser.ifStmt()
 	}
+	if parser.isLabel() {
+		return parser.labeledStmt()
+	}
 	if parser.match(WHILE) {
-		return parser.whiteStmt()
+		return parser.whiteStmt(Token{})
 	}
 	if parser.match(FOR) {
-		return parser.forStmt()
+		return parser.forStmt(Token{})
+	}
+	if parser.match(FUNCTION) {
+		return parser.fnDeclStmt()
+	}
+	if parser.match(RETURN) {
+		return parser.returnStmt()
+	}
+	if parser.match(BREAK, CONTINUE) {
+		return parser.jumpStmt()
+	}
+	if parser.match(IMPORT) {
+		return nil, parser.errorAt(parser.tokens[parser.current-1], "Imports are only allowed at the top level")
 	}
-  if parser.match(FUNCTION) {
-    return parser.fnDeclStmt()
-  }
 
 	return parser.block()
 }
 
+// importStmt parses 'import "path"' or 'import name "path"'. Without a
+// name the module is bound to its file name, which then has to be a valid
+// identifier.
+func (parser *Parser) importStmt() (Expr, error) {
+	keyword := parser.tokens[parser.current-1]
+
+	var name Token
+	if parser.check(IDENTIFIER) {
+		name = parser.advance()
+	}
+
+	path, err := parser.consume(STRING, "Expected module path after import")
+	if err != nil {
+		return nil, err
+	}
+
+	expr := ImportExpr{
+		Keyword: keyword,
+		Name:    name,
+		Path:    path,
+	}
+	if name.Type != IDENTIFIER && !isIdentifier(moduleName(path.Value.(string))) {
+		return nil, parser.errorAt(path, fmt.Sprintf("Cannot name module %v, use import name %v", path, path))
+	}
+	return expr, nil
+}
+
 func (parser *Parser) ifStmt() (Expr, error) {
+	keyword := parser.tokens[parser.current-1]
 	_, err := parser.consume(LEFT_PAREN, "Expect '(' after 'if'.")
 	if err != nil {
 		return nil, err
@@ -83,13 +188,84 @@ func (parser *Parser) ifStmt() (Expr, error) {
 	}
 
 	return IfExpr{
-		condition:  condition,
-		thenBranch: thenBranch,
-		elseBranch: elseBranch,
+		Keyword:   keyword,
+		Condition: condition,
+		Then:      thenBranch,
+		Else:      elseBranch,
 	}, nil
 }
 
-func (parser *Parser) whiteStmt() (Expr, error) {
+// isLabel reports whether the parser is looking at 'name:' in front of a
+// while or for loop.
+func (parser *Parser) isLabel() bool {
+	if !parser.check(IDENTIFIER) || parser.current+2 >= len(parser.tokens) {
+		return false
+	}
+	next := parser.tokens[parser.current+1].Type
+	loop := parser.tokens[parser.current+2].Type
+	return next == COLON && (loop == WHILE || loop == FOR)
+}
+
+func (parser *Parser) labeledStmt() (Expr, error) {
+	label := parser.advance()
+	parser.advance()
+
+	name := label.Value.(string)
+	if slices.Contains(parser.labels, name) {
+		return nil, parser.errorAt(label, fmt.Sprintf("Label %s is already in use", name))
+	}
+
+	parser.labels = append(parser.labels, name)
+	defer func() {
+		parser.labels = parser.labels[:len(parser.labels)-1]
+	}()
+
+	if parser.match(WHILE) {
+		return parser.whiteStmt(label)
+	}
+	parser.advance()
+	return parser.forStmt(label)
+}
+
+func (parser *Parser) jumpStmt() (Expr, error) {
+	keyword := parser.tokens[parser.current-1]
+	if parser.loopDepth == 0 {
+		return nil, parser.errorAt(keyword, fmt.Sprintf("Cannot use %v outside of a loop", keyword))
+	}
+
+	// Like a bare return, a label has to stay on the line of its keyword.
+	// Any other identifier starts the next statement.
+	var label Token
+	next := parser.tokens[parser.current]
+	if next.Type == IDENTIFIER && next.Line == keyword.Line && slices.Contains(parser.labels, next.Value.(string)) {
+		label = parser.advance()
+	}
+
+	if keyword.Type == BREAK {
+		return BreakExpr{
+			Keyword: keyword,
+			Label:   label,
+		}, nil
+	}
+	return ContinueExpr{
+		Keyword: keyword,
+		Label:   label,
+	}, nil
+}
+
+// loopBody parses the body of a while or for loop, where break and
+// continue are allowed.
+func (parser *Parser) loopBody() (Expr, error) {
+	parser.loopDepth++
+	defer func() {
+		parser.loopDepth--
+	}()
+
+	return parser.expression()
+}
+
+func (parser *Parser) whiteStmt(label Token) (Expr, error) {
+	keyword := parser.tokens[parser.current-1]
 	_, err := parser.consume(LEFT_PAREN, "Expect '(' after 'while'.")
 	if err != nil {
 		return nil, err
@@ -105,20 +281,23 @@ func (parser *Parser) whiteStmt() (Expr, error) {
 		return nil, err
 	}
 
-	loopBranch, err := parser.expression()
+	loopBranch, err := parser.loopBody()
 	if err != nil {
 		return nil, err
 	}
 
 	return WhileExpr{
-		condition:  condition,
-		loopBranch: BlockExpr{ 
-      program: []Expr{loopBranch},
-    },
+		Keyword:   keyword,
+		Label:     label,
+		Condition: condition,
+		Body: BlockExpr{
+			Body: []Expr{loopBranch},
+		},
 	}, nil
 }
 
-func (parser *Parser) forStmt() (Expr, error) {
+func (parser *Parser) forStmt(label Token) (Expr, error) {
+	keyword := parser.tokens[parser.current-1]
 	_, err := parser.consume(LEFT_PAREN, "Expect '(' after 'for'.")
 	if err != nil {
 		return nil, err
@@ -152,49 +331,116 @@ func (parser *Parser) forStmt() (Expr, error) {
 		return nil, err
 	}
 
-	loopBranchContent, err := parser.expression()
+	loopBranchContent, err := parser.loopBody()
 	if err != nil {
 		return nil, err
 	}
 
+	// The increment is kept apart from the body so that it still runs
+	// when the body is left early with continue.
 	loopBranch := BlockExpr{
-		program: []Expr{loopBranchContent, increment},
+		Body: []Expr{loopBranchContent},
 	}
 
 	return BlockExpr{
-		program: append([]Expr{initializer}, WhileExpr{
-			condition:  condition,
-			loopBranch: loopBranch,
+		Body: append([]Expr{initializer}, WhileExpr{
+			Keyword:   keyword,
+			Label:     label,
+			Condition: condition,
+			Body:      loopBranch,
+			Increment: increment,
 		}),
 	}, nil
 }
 
 func (parser *Parser) fnDeclStmt() (Expr, error) {
-  identifier, err := parser.consume(IDENTIFIER, "Expected Identifier after fn")
-  if err != nil {
-    return nil, err
-  }
+	keyword := parser.tokens[parser.current-1]
+	if !parser.check(IDENTIFIER) {
+		return parser.fnSignature(keyword)
+	}
+	identifier := parser.advance()
+
+	fn, err := parser.fnSignature(keyword)
+	if err != nil {
+		return nil, err
+	}
+
+	return FnDeclExpr{
+		Keyword:    keyword,
+		Name:       identifier,
+		Paren:      fn.Paren,
+		Params:     fn.Params,
+		RightParen: fn.RightParen,
+		Body:       fn.Body,
+	}, nil
+}
+
+// fnSignature parses the parameters and the body of the function started
+// by keyword.
+func (parser *Parser) fnSignature(keyword Token) (FnExpr, error) {
+	paren, err := parser.consume(LEFT_PAREN, "Expected ( after fn")
+	if err != nil {
+		return FnExpr{}, err
+	}
+
+	args, err := parser.finishArgs()
+	if err != nil {
+		return FnExpr{}, err
+	}
+	rightParen := parser.tokens[parser.current-1]
 
-  _, err = parser.consume(LEFT_PAREN, "Expected ( after fnDecl")
-  if err != nil {
-    return nil, err
-  }
+	// Loops outside the function cannot be targeted from inside its body.
+	loopDepth, labels := parser.loopDepth, parser.labels
+	parser.fnDepth++
+	parser.loopDepth, parser.labels = 0, nil
+	program, err := parser.expression()
+	parser.fnDepth--
+	parser.loopDepth, parser.labels = loopDepth, labels
+	if err != nil {
+		return FnExpr{}, err
+	}
 
-  args, err := parser.finishArgs()
-  if err != nil {
-    return nil, err
-  }
+	return FnExpr{
+		Keyword:    keyword,
+		Paren:      paren,
+		Params:     args,
+		RightParen: rightParen,
+		Body:       program,
+	}, nil
+}
 
-  program, err := parser.expression()
-  if err != nil {
-    return nil, err
-  }
+func (parser *Parser) returnStmt() (Expr, error) {
+	keyword := parser.tokens[parser.current-1]
+	if parser.fnDepth == 0 {
+		return nil, parser.errorAt(keyword, "Cannot return from top-level code")
+	}
 
-  return FnDeclExpr {
-    name: identifier,
-    args: args,
-    program: program,
-  }, nil
+	// A return without a value ends at a closing bracket of any kind, a
+	// comma, an else, a semicolon or the end of the line.
+	next := parser.tokens[parser.current]
+	if parser.isAtEnd() || next.Line != keyword.Line || endsReturn(next.Type) {
+		return ReturnExpr{
+			Keyword: keyword,
+		}, nil
+	}
+
+	value, err := parser.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	return ReturnExpr{
+		Keyword: keyword,
+		Value:   value,
+	}, nil
+}
+
+func endsReturn(tokenType TokenType) bool {
+	switch tokenType {
+	case RIGHT_PAREN, RIGHT_BRACE, RIGHT_BRACKET, COMMA, ELSE, SEMI:
+		return true
+	}
+	return false
 }
 
 func (parser *Parser) finishArgs() ([]Token, error) {
@@ -202,7 +448,7 @@ func (parser *Parser) finishArgs() ([]Token, error) {
 
 	if !parser.check(RIGHT_PAREN) {
 		if parser.isAtEnd() {
-			return nil, fmt.Errorf("Expected ')' after args")
+			return nil, parser.errorAt(parser.tokens[parser.current], "Expected ')' after args")
 		}
 
 		arg, err := parser.consume(IDENTIFIER, "Expected Identifier after (")
@@ -221,93 +467,149 @@ func (parser *Parser) finishArgs() ([]Token, error) {
 	}
 
 	_, err := parser.consume(RIGHT_PAREN, "Expected ')' after args")
-  if err != nil {
-    return nil, err
-  }
+	if err != nil {
+		return nil, err
+	}
 
 	return args, nil
 }
 
 func (parser *Parser) block() (Expr, error) {
 	if parser.match(LEFT_BRACE) {
+		brace := parser.tokens[parser.current-1]
+		if parser.isMapLiteral() {
+			return parser.mapLiteral()
+		}
+
 		program := []Expr{}
 		for !parser.match(RIGHT_BRACE) {
 			if parser.isAtEnd() {
-				return nil, fmt.Errorf("Expected } after block")
+				return nil, parser.errorAt(parser.tokens[parser.current], "Expected } after block")
 			}
 
+			start := parser.current
 			expr, err := parser.expression()
 			if err != nil {
-				return nil, err
+				parser.recover(err, start)
+				continue
 			}
 
 			program = append(program, expr)
+			parser.endStatement()
 		}
 
 		return BlockExpr{
-			program: program,
+			Brace:      brace,
+			RightBrace: parser.tokens[parser.current-1],
+			Body:       program,
 		}, nil
 	}
 
 	return parser.assignment()
 }
 
-func (parser *Parser) assignment() (Expr, error) {
-	if parser.match(IDENTIFIER) {
-		name := parser.tokens[parser.current-1]
-		for parser.match(EQUAL, COLON_EQ, PLUS_EQ, MINUS_EQ, STAR_EQ, SLASH_EQ, PERCENT_EQ) {
-			operator := parser.tokens[parser.current-1]
-			expr, err := parser.expression()
+// isMapLiteral decides whether the '{' just consumed opens a map literal
+// rather than a block. A map literal is either the empty map '{:}' or
+// starts with a string or number key followed by ':'.
+func (parser *Parser) isMapLiteral() bool {
+	if parser.check(COLON) {
+		return true
+	}
+	if !parser.check(STRING) && !parser.check(NUMBER) {
+		return false
+	}
+	return parser.tokens[parser.current+1].Type == COLON
+}
+
+func (parser *Parser) mapLiteral() (Expr, error) {
+	brace := parser.tokens[parser.current-1]
+	if parser.match(COLON) {
+		rightBrace, err := parser.consume(RIGHT_BRACE, "Expected '}' after empty map")
+		if err != nil {
+			return nil, err
+		}
+		return MapInitExpr{
+			Brace:      brace,
+			RightBrace: rightBrace,
+		}, nil
+	}
+
+	var keys []Expr
+	var values []Expr
+	for !parser.match(RIGHT_BRACE) {
+		if parser.isAtEnd() {
+			return nil, parser.errorAt(parser.tokens[parser.current], "Expected '}' after map literal")
+		}
+
+		key, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		_, err = parser.consume(COLON, "Expected ':' after map key")
+		if err != nil {
+			return nil, err
+		}
+		value, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+
+		if !parser.match(COMMA) {
+			_, err = parser.consume(RIGHT_BRACE, "Expected ',' or '}' after map entry")
 			if err != nil {
 				return nil, err
 			}
+			break
+		}
+	}
 
-			switch operator.Type {
-			case PLUS_EQ:
-				return parser.assignDesugared(name, PLUS, expr, operator.Line), nil
-			case MINUS_EQ:
-				return parser.assignDesugared(name, MINUS, expr, operator.Line), nil
-			case STAR_EQ:
-				return parser.assignDesugared(name, STAR, expr, operator.Line), nil
-			case SLASH_EQ:
-				return parser.assignDesugared(name, SLASH, expr, operator.Line), nil
-			case PERCENT_EQ:
-				return parser.assignDesugared(name, PERCENT, expr, operator.Line), nil
+	return MapInitExpr{
+		Brace:      brace,
+		RightBrace: parser.tokens[parser.current-1],
+		Keys:       keys,
+		Values:     values,
+	}, nil
+}
+
+func (parser *Parser) assignment() (Expr, error) {
+	expr, err := parser.logicalOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.match(EQUAL, COLON_EQ, PLUS_EQ, MINUS_EQ, STAR_EQ, SLASH_EQ, PERCENT_EQ) {
+		operator := parser.tokens[parser.current-1]
+		value, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		switch target := expr.(type) {
+		case LiteralExpr:
+			if target.Token.Type == IDENTIFIER {
+				return AssignExpr{
+					Name:     target.Token,
+					Value:    value,
+					Operator: operator,
+				}, nil
 			}
+		case IndexExpr:
+			if operator.Type != COLON_EQ {
+				return IndexAssignExpr{
+					Object:   target.Object,
+					Index:    target.Index,
+					Value:    value,
+					Operator: operator,
+				}, nil
+			}
+		}
 
-			return AssignExpr{
-				name:     name,
-				expr:     expr,
-				operator: operator,
-			}, nil
-		}
-		parser.current--
-	}
-	return parser.logicalOr()
-}
-
-func (parser *Parser) assignDesugared(name Token, operator TokenType, expr Expr, line int) AssignExpr {
-	return AssignExpr{
-		name: name,
-		expr: BinaryExpr{
-			operator: Token{
-				Type:  operator,
-				Value: "",
-				Line:  line,
-			},
-			leftExpr: LiteralExpr{
-				value: Token{
-					Type:  IDENTIFIER,
-					Value: name.Value,
-					Line:  line,
-				},
-			},
-			rightExpr: expr,
-		},
-		operator: Token{
-			Type: EQUAL, Value: "", Line: line,
-		},
+		return nil, parser.errorAt(operator, "Invalid assignment target")
 	}
+
+	return expr, nil
 }
 
 func (parser *Parser) logicalOr() (Expr, error) {
@@ -322,10 +624,10 @@ func (parser *Parser) logicalOr() (Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		expr = BinaryExpr{
-			operator:  operator,
-			rightExpr: rightExpr,
-			leftExpr:  expr,
+		expr = LogicalExpr{
+			Operator: operator,
+			Right:    rightExpr,
+			Left:     expr,
 		}
 	}
 
@@ -344,10 +646,10 @@ func (parser *Parser) logicalAnd() (Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		expr = BinaryExpr{
-			operator:  operator,
-			rightExpr: rightExpr,
-			leftExpr:  expr,
+		expr = LogicalExpr{
+			Operator: operator,
+			Right:    rightExpr,
+			Left:     expr,
 		}
 	}
 
@@ -367,9 +669,9 @@ func (parser *Parser) equality() (Expr, error) {
 			return nil, err
 		}
 		expr = BinaryExpr{
-			operator:  operator,
-			rightExpr: rightExpr,
-			leftExpr:  expr,
+			Operator: operator,
+			Right:    rightExpr,
+			Left:     expr,
 		}
 	}
 
@@ -389,9 +691,9 @@ func (parser *Parser) comparison() (Expr, error) {
 			return nil, err
 		}
 		expr = BinaryExpr{
-			operator:  operator,
-			rightExpr: rightExpr,
-			leftExpr:  expr,
+			Operator: operator,
+			Right:    rightExpr,
+			Left:     expr,
 		}
 	}
 
@@ -404,16 +706,16 @@ func (parser *Parser) term() (Expr, error) {
 		return nil, err
 	}
 
-	for parser.match(PLUS, MINUS) {
+	for parser.match(PLUS, MINUS, BAR) {
 		operator := parser.tokens[parser.current-1]
 		rightExpr, err := parser.factor()
 		if err != nil {
 			return nil, err
 		}
 		expr = BinaryExpr{
-			operator:  operator,
-			rightExpr: rightExpr,
-			leftExpr:  expr,
+			Operator: operator,
+			Right:    rightExpr,
+			Left:     expr,
 		}
 	}
 
@@ -426,16 +728,16 @@ func (parser *Parser) factor() (Expr, error) {
 		return nil, err
 	}
 
-	for parser.match(STAR, SLASH, PERCENT) {
+	for parser.match(STAR, SLASH, PERCENT, AMP) {
 		operator := parser.tokens[parser.current-1]
 		rightExpr, err := parser.unary()
 		if err != nil {
 			return nil, err
 		}
 		expr = BinaryExpr{
-			operator:  operator,
-			rightExpr: rightExpr,
-			leftExpr:  expr,
+			Operator: operator,
+			Right:    rightExpr,
+			Left:     expr,
 		}
 	}
 
@@ -444,65 +746,75 @@ func (parser *Parser) factor() (Expr, error) {
 
 func (parser *Parser) unary() (Expr, error) {
 	if parser.match(BANG, MINUS, HASHTAG) {
-    operator := parser.tokens[parser.current-1]
-		expr, err := parser.index()
+		operator := parser.tokens[parser.current-1]
+		expr, err := parser.call()
 		if err != nil {
 			return nil, err
 		}
 		return UnaryExpr{
-			operator: operator,
-			expr:     expr,
+			Operator: operator,
+			Operand:  expr,
 		}, nil
 	}
 
-	expr, err := parser.index()
+	expr, err := parser.call()
 	if err != nil {
 		return nil, err
 	}
 	return expr, nil
 }
 
-func (parser *Parser) index() (Expr, error) {
-  expr, err := parser.call()
-  if err != nil {
-    return nil, err
-  }
-
-  if parser.match(LEFT_BRACKET) {
-    arg, err := parser.expression()
-    if err != nil {
-      return nil, err
-    }
-    _, err = parser.consume(RIGHT_BRACKET, "Expected ']' after index notation")
-    if err != nil {
-      return nil, err
-    }
-
-    return IndexExpr{
-      value: expr, 
-      index: arg,
-    }, nil
-  }
-
-  return expr, nil
-}
-
 func (parser *Parser) call() (Expr, error) {
 	expr, err := parser.primary()
 	if err != nil {
 		return nil, err
 	}
 
-	if parser.match(LEFT_PAREN) {
-		args, err := parser.finishCall()
-		if err != nil {
-			return nil, err
-		}
+	for {
+		if parser.match(LEFT_PAREN) {
+			paren := parser.tokens[parser.current-1]
+			args, err := parser.finishCall()
+			if err != nil {
+				return nil, err
+			}
 
-		return CallExpr{
-			f:    expr,
-			args: args,
-		}, nil
+			expr = CallExpr{
+				Callee:     expr,
+				Paren:      paren,
+				RightParen: parser.tokens[parser.current-1],
+				Args:       args,
+			}
+		} else if parser.match(DOT) {
+			dot := parser.tokens[parser.current-1]
+			name, err := parser.consume(IDENTIFIER, "Expected member name after '.'")
+			if err != nil {
+				return nil, err
+			}
+
+			expr = GetExpr{
+				Object: expr,
+				Dot:    dot,
+				Name:   name,
+			}
+		} else if parser.match(LEFT_BRACKET) {
+			bracket := parser.tokens[parser.current-1]
+			arg, err := parser.expression()
+			if err != nil {
+				return nil, err
+			}
+			_, err = parser.consume(RIGHT_BRACKET, "Expected ']' after index notation")
+			if err != nil {
+				return nil, err
+			}
+
+			expr = IndexExpr{
+				Object:  expr,
+				Bracket: bracket,
+				Index:   arg,
+			}
+		} else {
+			break
+		}
 	}
 
 	return expr, nil
@@ -513,7 +825,7 @@ func (parser *Parser) finishCall() ([]Expr, error) {
 
 	if !parser.check(RIGHT_PAREN) {
 		if parser.isAtEnd() {
-			return nil, fmt.Errorf("Expected ')' after args")
+			return nil, parser.errorAt(parser.tokens[parser.current], "Expected ')' after args")
 		}
 
 		arg, err := parser.expression()
@@ -532,9 +844,9 @@ func (parser *Parser) finishCall() ([]Expr, error) {
 	}
 
 	_, err := parser.consume(RIGHT_PAREN, "Expected ')' after args")
-  if err != nil {
-    return nil, err
-  }
+	if err != nil {
+		return nil, err
+	}
 
 	return args, nil
 }
@@ -542,41 +854,44 @@ func (parser *Parser) finishCall() ([]Expr, error) {
 func (parser *Parser) primary() (Expr, error) {
 	if parser.match(IDENTIFIER, NUMBER, STRING, TRUE, FALSE) {
 		return LiteralExpr{
-			value: parser.tokens[parser.current-1],
+			Token: parser.tokens[parser.current-1],
 		}, nil
 	}
 
-  if parser.match(LEFT_BRACKET) {
-    var values []Expr
-    if !parser.check(RIGHT_BRACKET) {
-      if parser.isAtEnd() {
-        return nil, fmt.Errorf("Expected ']' after array initializer")
-      }
-
-      val, err := parser.expression()
-      if err != nil {
-        return nil, err
-      }
-      values = append(values, val)
-
-      for parser.match(COMMA) {
-        val, err := parser.expression()
-        if err != nil {
-          return nil, err
-        }
-        values = append(values, val)
-      }
-    }
-    
-    _, err := parser.consume(RIGHT_BRACKET, "Expected ']' after array initializer")
-    if err != nil {
-      return nil, err
-    }
-
-    return ArrayInitExpr {
-      values: values,
-    }, nil
-  }
+	if parser.match(LEFT_BRACKET) {
+		bracket := parser.tokens[parser.current-1]
+		var values []Expr
+		if !parser.check(RIGHT_BRACKET) {
+			if parser.isAtEnd() {
+				return nil, parser.errorAt(parser.tokens[parser.current], "Expected ']' after array initializer")
+			}
+
+			val, err := parser.expression()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, val)
+
+			for parser.match(COMMA) {
+				val, err := parser.expression()
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, val)
+			}
+		}
+
+		rightBracket, err := parser.consume(RIGHT_BRACKET, "Expected ']' after array initializer")
+		if err != nil {
+			return nil, err
+		}
+
+		return ArrayInitExpr{
+			Bracket:      bracket,
+			RightBracket: rightBracket,
+			Elements:     values,
+		}, nil
+	}
 
 	if parser.match(LEFT_PAREN) {
 		expr, err := parser.expression()
@@ -589,11 +904,11 @@ func (parser *Parser) primary() (Expr, error) {
 		}
 
 		return GroupingExpr{
-			expr: expr,
+			Inner: expr,
 		}, nil
 	}
 
-	return nil, fmt.Errorf("[ERROR] Syntax Error at Line %d\n", parser.tokens[parser.current].Line)
+	return nil, parser.errorAt(parser.tokens[parser.current], fmt.Sprintf("Syntax Error, unexpected %v", parser.tokens[parser.current]))
 }
 
 func (parser *Parser) match(tokenTypes ...TokenType) bool {
@@ -620,7 +935,15 @@ func (parser *Parser) consume(tokenType TokenType, message string) (Token, error
 		return parser.advance(), nil
 	}
 
-	return parser.advance(), fmt.Errorf("[ERROR] %s at Line %d", message, parser.tokens[parser.current].Line)
+	token := parser.tokens[parser.current]
+	return parser.advance(), parser.errorAt(token, message)
+}
+
+func (parser *Parser) errorAt(token Token, message string) error {
+	return &ParseError{
+		Span:    token.Span(),
+		Message: message,
+	}
 }
 
 func (parser Parser) check(tokenType TokenType) bool {
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRecovery(t *testing.T) {
+	src := `x := )
+y := 2
+fn f() {
+  a := ]
+  b := 1
+}
+print(y +)
+z := 3`
+
+	scanner := CreateScanner(src)
+	if err := scanner.ScanTokens(); err != nil {
+		t.Fatal(err)
+	}
+	parser := CreateParser(scanner.Tokens)
+	program, err := parser.Parse()
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("Got %v, want an ErrorList", err)
+	}
+	lines := []int{1, 4, 7}
+	if len(list) != len(lines) {
+		t.Fatalf("Got %d errors, want %d:\n%v", len(list), len(lines), err)
+	}
+	for i, line := range lines {
+		span, ok := ErrorSpan(list[i])
+		if !ok || span.Line != line {
+			t.Errorf("Error %d is at %v, want line %d", i, span, line)
+		}
+	}
+
+	// Everything that did parse is kept, including the rest of the block.
+	if len(program) != 3 {
+		t.Fatalf("Got %d statements, want 3", len(program))
+	}
+	decl, ok := program[1].(FnDeclExpr)
+	if !ok {
+		t.Fatalf("Statement 1 is %T, want FnDeclExpr", program[1])
+	}
+	if body := decl.Body.(BlockExpr).Body; len(body) != 1 {
+		t.Errorf("Function body has %d statements, want 1", len(body))
+	}
+}
+
+func TestJumpLabel(t *testing.T) {
+	program := parse(t, `outer: while (c) { if (x) continue s += 1 if (y) break outer }`)
+
+	body := program[0].(WhileExpr).Body.(BlockExpr).Body[0].(BlockExpr).Body
+	if len(body) != 3 {
+		t.Fatalf("Loop body has %d statements, want 3", len(body))
+	}
+	if jump := body[0].(IfExpr).Then.(ContinueExpr); jump.Label.Value != nil {
+		t.Errorf("continue took %v as its label", jump.Label)
+	}
+	if assign, ok := body[1].(AssignExpr); !ok || assign.Name.Value != "s" {
+		t.Errorf("Statement 1 is %#v, want an assignment to s", body[1])
+	}
+	if jump := body[2].(IfExpr).Then.(BreakExpr); jump.Label.Value != "outer" {
+		t.Errorf("break has label %v, want outer", jump.Label)
+	}
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Print returns source for program that parses back to an equal tree, see
+// Equal. Trees from the parser keep the line breaks of their source, like
+// Format lays them out but without comments. Trees built by hand, without
+// token positions, are laid out canonically and get parentheses wherever
+// the grammar needs them to keep the structure of an operand, and a ';'
+// after statements the next one would otherwise continue. Trees holding
+// values that lagn source cannot express, like a string containing '"',
+// are not printed and an error is returned.
+func Print(program []Expr) (string, error) {
+	for _, expr := range program {
+		var err error
+		WalkTokens(expr, func(token Token) {
+			if err == nil {
+				err = expressible(token)
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	p := &printer{}
+	return p.program(program), nil
+}
+
+// expressible reports an error for a token that cannot be written as
+// source.
+func expressible(token Token) error {
+	switch value := token.Value.(type) {
+	case string:
+		if token.Type == STRING && strings.Contains(value, `"`) {
+			return fmt.Errorf("String %q cannot be written in lagn, it contains '\"'", value)
+		}
+		if token.Type == IDENTIFIER && !isIdentifier(value) {
+			return fmt.Errorf("%q is not a valid identifier", value)
+		}
+	case int64:
+		// Only the magnitude of a negative number is scanned, and the
+		// magnitude of the smallest Int does not fit into one.
+		if value == math.MinInt64 {
+			return fmt.Errorf("Int %d cannot be written in lagn", value)
+		}
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("Float %v cannot be written in lagn", value)
+		}
+	}
+	return nil
+}
+
+// How tightly expressions bind, from the loosest to the tightest. An
+// operand that binds looser than its position requires is printed in
+// parentheses.
+const (
+	// precExpression is anything the grammar only accepts as a whole
+	// expression: control flow, functions, blocks and map literals.
+	precExpression = iota
+	precAssignment
+	precOr
+	precAnd
+	precEquality
+	precComparison
+	precTerm
+	precFactor
+	precUnary
+	precCall
+	precPrimary
+)
+
+// binaryPrecedence returns how tightly a binary or logical operator binds.
+func binaryPrecedence(operator TokenType) int {
+	switch operator {
+	case BAR_BAR:
+		return precOr
+	case AMP_AMP:
+		return precAnd
+	case EQUAL_EQ, BANG_EQ:
+		return precEquality
+	case GREATER, GREATER_EQ, LESS, LESS_EQ:
+		return precComparison
+	case PLUS, MINUS, BAR:
+		return precTerm
+	default:
+		return precFactor
+	}
+}
+
+// operands returns the precedence required of the left and the right
+// operand of a binary operator. Comparisons group to the right, every
+// other operator to the left.
+func operands(operator TokenType) (int, int) {
+	prec := binaryPrecedence(operator)
+	if prec == precComparison {
+		return prec + 1, prec
+	}
+	return prec, prec + 1
+}
+
+// continues reports whether expr, written as a statement, starts with a
+// token that would continue the statement before it: '(' or '[' are read
+// as a call or an index and '-' as a subtraction.
+func continues(expr Expr) bool {
+	prec := precExpression
+	for {
+		if precedence(expr) < prec {
+			return true
+		}
+		switch e := expr.(type) {
+		case GroupingExpr, ArrayInitExpr:
+			return true
+		case UnaryExpr:
+			return e.Operator.Type == MINUS
+		case LiteralExpr:
+			return precedence(e) == precUnary
+		case BinaryExpr:
+			expr = e.Left
+			prec, _ = operands(e.Operator.Type)
+		case LogicalExpr:
+			expr = e.Left
+			prec, _ = operands(e.Operator.Type)
+		case CallExpr:
+			expr, prec = e.Callee, precCall
+		case IndexExpr:
+			expr, prec = e.Object, precCall
+		case IndexAssignExpr:
+			expr, prec = e.Object, precCall
+		case GetExpr:
+			expr, prec = e.Object, precCall
+		default:
+			return false
+		}
+	}
+}
+
+func precedence(expr Expr) int {
+	switch e := expr.(type) {
+	case AssignExpr, IndexAssignExpr:
+		return precAssignment
+	case LogicalExpr:
+		return binaryPrecedence(e.Operator.Type)
+	case BinaryExpr:
+		return binaryPrecedence(e.Operator.Type)
+	case UnaryExpr:
+		return precUnary
+	case CallExpr, IndexExpr, GetExpr:
+		return precCall
+	case LiteralExpr:
+		// The scanner never reads a negative number, it is printed as one
+		// but read back as a negation.
+		if strings.HasPrefix(literal(e.Token), "-") {
+			return precUnary
+		}
+		return precPrimary
+	case GroupingExpr, ArrayInitExpr:
+		return precPrimary
+	default:
+		return precExpression
+	}
+}
+
+// Equal reports whether a and b are the same tree: the same nodes with the
+// same operators, names and literals. Where the tokens are in the source,
+// punctuation and keywords, parentheses and what the Resolver fills in are
+// ignored, and a negative number equals the negation of its magnitude,
+// which is how it is read back.
+func Equal(a Expr, b Expr) bool {
+	return equal(reflect.ValueOf(normalize(a)), reflect.ValueOf(normalize(b)))
+}
+
+var (
+	tokenType = reflect.TypeOf(Token{})
+	exprType  = reflect.TypeOf((*Expr)(nil)).Elem()
+)
+
+// punctuation holds the names of the fields whose tokens only mark where
+// the syntax of a node is, their type is implied by the node.
+var punctuation = map[string]bool{
+	"Paren":        true,
+	"RightParen":   true,
+	"Brace":        true,
+	"RightBrace":   true,
+	"Bracket":      true,
+	"RightBracket": true,
+	"Keyword":      true,
+	"Dot":          true,
+}
+
+// normalize returns expr without the parentheses around it and with a
+// negative number as a negation.
+func normalize(expr Expr) Expr {
+	for {
+		switch e := expr.(type) {
+		case GroupingExpr:
+			expr = e.Inner
+		case LiteralExpr:
+			switch value := e.Token.Value.(type) {
+			case int64:
+				if value < 0 && value != math.MinInt64 {
+					e.Token.Value = -value
+					return UnaryExpr{Operator: Token{Type: MINUS}, Operand: e}
+				}
+			case float64:
+				if math.Signbit(value) {
+					e.Token.Value = -value
+					return UnaryExpr{Operator: Token{Type: MINUS}, Operand: e}
+				}
+			}
+			return e
+		default:
+			return expr
+		}
+	}
+}
+
+func equal(a reflect.Value, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Type() == exprType {
+			x, y := normalize(a.Interface().(Expr)), normalize(b.Interface().(Expr))
+			return equal(reflect.ValueOf(x), reflect.ValueOf(y))
+		}
+		return equal(a.Elem(), b.Elem())
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if a.Type() == tokenType {
+			x, y := a.Interface().(Token), b.Interface().(Token)
+			return x.Type == y.Type && literal(x) == literal(y)
+		}
+		for i := 0; i < a.NumField(); i++ {
+			field := a.Type().Field(i)
+			if field.Anonymous || punctuation[field.Name] || field.Name == "Binding" || field.Name == "Slots" {
+				continue
+			}
+			if !equal(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+}
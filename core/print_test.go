@@ -0,0 +1,214 @@
+package core
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func parse(t *testing.T, src string) []Expr {
+	t.Helper()
+
+	scanner := CreateScanner(src)
+	err := scanner.ScanTokens()
+	if err != nil {
+		t.Fatalf("Scan %q: %v", src, err)
+	}
+	parser := CreateParser(scanner.Tokens)
+	program, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Parse %q: %v", src, err)
+	}
+	return program
+}
+
+// roundTrip prints program, parses the output back and fails unless the
+// trees are equal and printing again gives the same source.
+func roundTrip(t *testing.T, program []Expr) string {
+	t.Helper()
+
+	src, err := Print(program)
+	if err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	parsed := parse(t, src)
+	if len(parsed) != len(program) {
+		t.Fatalf("Printed %d statements, read back %d:\n%s", len(program), len(parsed), src)
+	}
+	for i := range program {
+		if !Equal(program[i], parsed[i]) {
+			t.Errorf("Statement %d differs after printing:\n%s", i, src)
+		}
+	}
+	again, err := Print(parsed)
+	if err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	if again != src {
+		t.Errorf("Printing is not stable:\n%s\nthen\n%s", src, again)
+	}
+	return src
+}
+
+func identifier(name string) Token {
+	return Token{Type: IDENTIFIER, Value: name}
+}
+
+func variable(name string) Expr {
+	return LiteralExpr{Token: identifier(name)}
+}
+
+func number(value any) Expr {
+	return LiteralExpr{Token: Token{Type: NUMBER, Value: value}}
+}
+
+func str(value string) Expr {
+	return LiteralExpr{Token: Token{Type: STRING, Value: value}}
+}
+
+func binary(left Expr, operator TokenType, right Expr) Expr {
+	return BinaryExpr{Left: left, Operator: Token{Type: operator}, Right: right}
+}
+
+func TestPrintExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.lagn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roundTrip(t, parse(t, string(content)))
+		})
+	}
+}
+
+func TestPrintHandBuilt(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []Expr
+		want    string
+	}{
+		{
+			name:    "call",
+			program: []Expr{CallExpr{Callee: variable("x"), Args: []Expr{variable("x")}}},
+			want:    "x(x)\n",
+		},
+		{
+			name:    "right operand",
+			program: []Expr{binary(variable("x"), MINUS, binary(variable("y"), MINUS, number(int64(1))))},
+			want:    "x - (y - 1)\n",
+		},
+		{
+			name:    "negative literal",
+			program: []Expr{binary(number(int64(-2)), STAR, number(-1.5))},
+			want:    "-2 * -1.5\n",
+		},
+		{
+			name: "statements that would merge",
+			program: []Expr{
+				CallExpr{Callee: variable("f")},
+				GroupingExpr{Inner: binary(variable("a"), PLUS, variable("b"))},
+				ArrayInitExpr{Elements: []Expr{number(int64(1))}},
+				UnaryExpr{Operator: Token{Type: MINUS}, Operand: variable("x")},
+				binary(binary(variable("a"), PLUS, variable("b")), STAR, variable("c")),
+				number(int64(-1)),
+			},
+			want: "f();\n(a + b);\n[1];\n-x;\n(a + b) * c;\n-1\n",
+		},
+		{
+			name: "keywords and blocks",
+			program: []Expr{
+				WhileExpr{Condition: LiteralExpr{Token: Token{Type: TRUE}}, Body: BlockExpr{Body: []Expr{BreakExpr{}}}},
+				IfExpr{Condition: variable("a"), Then: str("yes"), Else: str("no")},
+			},
+			want: "while (true) break\nif (a) \"yes\" else \"no\"\n",
+		},
+		{
+			name:    "else after an if without one",
+			program: []Expr{IfExpr{Condition: variable("a"), Then: IfExpr{Condition: variable("b"), Then: variable("x")}, Else: variable("y")}},
+			want:    "if (a) (if (b) x) else y\n",
+		},
+		{
+			name: "else after a loop ending in an if",
+			program: []Expr{IfExpr{
+				Condition: variable("a"),
+				Then:      WhileExpr{Condition: variable("c"), Body: BlockExpr{Body: []Expr{IfExpr{Condition: variable("b"), Then: BreakExpr{}}}}},
+				Else:      variable("y"),
+			}},
+			want: "if (a) (while (c) if (b) break) else y\n",
+		},
+		{
+			name:    "else chain",
+			program: []Expr{IfExpr{Condition: variable("a"), Then: IfExpr{Condition: variable("b"), Then: variable("x"), Else: variable("z")}, Else: variable("y")}},
+			want:    "if (a) if (b) x else z else y\n",
+		},
+		{
+			name:    "bare return",
+			program: []Expr{CallExpr{Callee: FnExpr{Body: ReturnExpr{}}}},
+			want:    "(fn() return)()\n",
+		},
+		{
+			name:    "bare return before an argument",
+			program: []Expr{CallExpr{Callee: variable("f"), Args: []Expr{FnExpr{Body: ReturnExpr{}}, number(int64(1))}}},
+			want:    "f(fn() return, 1)\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := roundTrip(t, test.program)
+			if src != test.want {
+				t.Errorf("Printed\n%s\nwant\n%s", src, test.want)
+			}
+		})
+	}
+}
+
+func TestPrintInexpressible(t *testing.T) {
+	tests := []struct {
+		name string
+		expr Expr
+	}{
+		{"quote", str(`say "hi"`)},
+		{"nan", number(math.NaN())},
+		{"infinity", number(math.Inf(-1))},
+		{"smallest int", number(int64(math.MinInt64))},
+		{"keyword as name", AssignExpr{Name: identifier("while"), Operator: Token{Type: COLON_EQ}, Value: number(int64(1))}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src, err := Print([]Expr{test.expr})
+			if err == nil {
+				t.Errorf("Printed %q, want an error", src)
+			}
+		})
+	}
+}
+
+func TestEqualDifferences(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Expr
+	}{
+		{"operator", binary(variable("x"), MINUS, variable("y")), binary(variable("x"), PLUS, variable("y"))},
+		{"name", variable("x"), variable("y")},
+		{"literal kind", number(int64(1)), number(1.0)},
+		{"string and number", str("1"), number(int64(1))},
+		{"grouping", binary(binary(variable("a"), MINUS, variable("b")), MINUS, variable("c")), binary(variable("a"), MINUS, GroupingExpr{Inner: binary(variable("b"), MINUS, variable("c"))})},
+		{"break and continue", BreakExpr{}, ContinueExpr{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if Equal(test.a, test.b) {
+				t.Errorf("Equal reports the trees as equal")
+			}
+		})
+	}
+}
@@ -0,0 +1,429 @@
+package core
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Binding locates a local variable: Depth scopes out from where it is
+// used, at index Slot of that scope. Globals have no Binding and are looked
+// up by name.
+type Binding struct {
+	Depth int
+	Slot  int
+}
+
+// Reference links a use of a variable to the token that declares it, the
+// name of an assignment, a parameter, a function or an import.
+type Reference struct {
+	Name        Token
+	Declaration Token
+}
+
+// resolveScope mirrors one Environment created by a BlockExpr or a
+// function call. Slots are assigned up front to every name declared
+// directly in the scope, declared tracks which declarations have been
+// passed so far.
+type resolveScope struct {
+	slots    map[string]int
+	size     int
+	declared map[string]bool
+	fnDepth  int
+	// declarations holds the first declaring token of every name and uses
+	// the names that resolved to the scope. A closure may use a name that
+	// is declared after it, so uses are only linked once the scope ends.
+	declarations map[string]Token
+	uses         []Token
+}
+
+type Resolver struct {
+	globals  *Environment
+	topLevel map[string]bool
+	declared map[string]bool
+	scopes   []*resolveScope
+	fnDepth  int
+	errors   ErrorList
+
+	// Top-level declarations and the uses of globals, linked by name at
+	// the end of Resolve.
+	declarations map[string]Token
+	uses         []Token
+	references   []Reference
+}
+
+func CreateResolver(globals *Environment) Resolver {
+	return Resolver{
+		globals:      globals,
+		topLevel:     make(map[string]bool),
+		declared:     make(map[string]bool),
+		declarations: make(map[string]Token),
+	}
+}
+
+// Resolve binds every local variable in program to a scope depth and slot
+// and returns the annotated program. Names that cannot be resolved are
+// globals, unless they are used before their declaration or never declared
+// at all, which is reported together with duplicate declarations as an
+// ErrorList. Programs must be resolved before they are interpreted.
+func (resolver *Resolver) Resolve(program []Expr) ([]Expr, error) {
+	var names []string
+	for _, expr := range program {
+		scopeDeclarations(expr, &names)
+	}
+	for _, name := range names {
+		resolver.topLevel[name] = true
+	}
+
+	resolved := resolver.exprs(program)
+	resolver.link(resolver.uses, resolver.declarations)
+	if len(resolver.errors) > 0 {
+		return resolved, resolver.errors
+	}
+	return resolved, nil
+}
+
+// References returns every use of a variable declared in the program that
+// the last Resolve found, linked to its declaration. Builtins and globals
+// declared by earlier programs have no declaration and are left out.
+func (resolver *Resolver) References() []Reference {
+	return resolver.references
+}
+
+func (resolver *Resolver) link(uses []Token, declarations map[string]Token) {
+	for _, use := range uses {
+		if declaration, ok := declarations[use.String()]; ok {
+			resolver.references = append(resolver.references, Reference{
+				Name:        use,
+				Declaration: declaration,
+			})
+		}
+	}
+}
+
+// scopeDeclarations appends the names declared by expr into the scope it
+// runs in, without descending into blocks and functions, which open scopes
+// of their own.
+func scopeDeclarations(expr Expr, names *[]string) {
+	declare := func(name string) {
+		for _, n := range *names {
+			if n == name {
+				return
+			}
+		}
+		*names = append(*names, name)
+	}
+
+	switch e := expr.(type) {
+	case AssignExpr:
+		scopeDeclarations(e.Value, names)
+		if e.Operator.Type == COLON_EQ {
+			declare(e.Name.String())
+		}
+	case FnDeclExpr:
+		declare(e.Name.String())
+	case ImportExpr:
+		declare(e.Alias().String())
+	case GetExpr:
+		scopeDeclarations(e.Object, names)
+	case BinaryExpr:
+		scopeDeclarations(e.Left, names)
+		scopeDeclarations(e.Right, names)
+	case LogicalExpr:
+		scopeDeclarations(e.Left, names)
+		scopeDeclarations(e.Right, names)
+	case UnaryExpr:
+		scopeDeclarations(e.Operand, names)
+	case GroupingExpr:
+		scopeDeclarations(e.Inner, names)
+	case IfExpr:
+		scopeDeclarations(e.Condition, names)
+		scopeDeclarations(e.Then, names)
+		if e.Else != nil {
+			scopeDeclarations(e.Else, names)
+		}
+	case WhileExpr:
+		scopeDeclarations(e.Condition, names)
+		if e.Increment != nil {
+			scopeDeclarations(e.Increment, names)
+		}
+	case CallExpr:
+		scopeDeclarations(e.Callee, names)
+		for _, arg := range e.Args {
+			scopeDeclarations(arg, names)
+		}
+	case ArrayInitExpr:
+		for _, element := range e.Elements {
+			scopeDeclarations(element, names)
+		}
+	case IndexExpr:
+		scopeDeclarations(e.Object, names)
+		scopeDeclarations(e.Index, names)
+	case IndexAssignExpr:
+		scopeDeclarations(e.Object, names)
+		scopeDeclarations(e.Index, names)
+		scopeDeclarations(e.Value, names)
+	case MapInitExpr:
+		for i := range e.Keys {
+			scopeDeclarations(e.Keys[i], names)
+			scopeDeclarations(e.Values[i], names)
+		}
+	case ReturnExpr:
+		if e.Value != nil {
+			scopeDeclarations(e.Value, names)
+		}
+	}
+}
+
+func (resolver *Resolver) errorAt(token Token, format string, args ...any) {
+	resolver.errors = append(resolver.errors, &ResolveError{
+		Span:    token.Span(),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (resolver *Resolver) beginScope(exprs []Expr, params []Token) *resolveScope {
+	var names []string
+	for _, param := range params {
+		names = append(names, param.String())
+	}
+	for _, expr := range exprs {
+		scopeDeclarations(expr, &names)
+	}
+
+	scope := &resolveScope{
+		slots:        make(map[string]int, len(names)),
+		size:         len(names),
+		declared:     make(map[string]bool),
+		fnDepth:      resolver.fnDepth,
+		declarations: make(map[string]Token),
+	}
+	for i, name := range names {
+		if _, ok := scope.slots[name]; !ok {
+			scope.slots[name] = i
+		}
+	}
+	for _, param := range params {
+		scope.declared[param.String()] = true
+		if _, ok := scope.declarations[param.String()]; !ok {
+			scope.declarations[param.String()] = param
+		}
+	}
+
+	resolver.scopes = append(resolver.scopes, scope)
+	return scope
+}
+
+func (resolver *Resolver) endScope() {
+	scope := resolver.scopes[len(resolver.scopes)-1]
+	resolver.link(scope.uses, scope.declarations)
+	resolver.scopes = resolver.scopes[:len(resolver.scopes)-1]
+}
+
+// resolve finds the Binding of a variable named by token. Within the
+// current function only declarations that have already been passed count,
+// so a name declared later in a block still refers to the enclosing
+// variable. Enclosing functions run before their closures are called, so
+// every name they declare is visible.
+func (resolver *Resolver) resolve(token Token, assignment bool) *Binding {
+	name := token.String()
+	pending := false
+	for i := len(resolver.scopes) - 1; i >= 0; i-- {
+		scope := resolver.scopes[i]
+		slot, ok := scope.slots[name]
+		if !ok {
+			continue
+		}
+		if scope.declared[name] || scope.fnDepth != resolver.fnDepth {
+			scope.uses = append(scope.uses, token)
+			return &Binding{
+				Depth: len(resolver.scopes) - 1 - i,
+				Slot:  slot,
+			}
+		}
+		pending = true
+	}
+
+	if _, err := resolver.globals.findVar(name); err == nil {
+		resolver.uses = append(resolver.uses, token)
+		return nil
+	}
+	if resolver.declared[name] || (resolver.fnDepth > 0 && resolver.topLevel[name]) {
+		resolver.uses = append(resolver.uses, token)
+		return nil
+	}
+
+	if pending || resolver.topLevel[name] {
+		resolver.errorAt(token, "Variable %s used before declaration", name)
+	} else if assignment {
+		resolver.errorAt(token, "Cannot assign to undeclared variable %s", name)
+	} else {
+		resolver.errorAt(token, "Undeclared variable %s", name)
+	}
+	return nil
+}
+
+// declare marks the variable named by token as declared in the innermost
+// scope and returns its Binding, or nil for a global.
+func (resolver *Resolver) declare(token Token) *Binding {
+	name := token.String()
+	declared := resolver.declared
+	declarations := resolver.declarations
+	var binding *Binding
+	if len(resolver.scopes) > 0 {
+		scope := resolver.scopes[len(resolver.scopes)-1]
+		declared = scope.declared
+		declarations = scope.declarations
+		binding = &Binding{Slot: scope.slots[name]}
+	}
+	if _, ok := declarations[name]; !ok {
+		declarations[name] = token
+	}
+
+	if declared[name] {
+		resolver.errorAt(token, "Variable %s is already declared in this scope", name)
+	}
+	declared[name] = true
+	return binding
+}
+
+// currentDeclared returns the set that declarations in the current scope
+// are recorded in.
+func (resolver *Resolver) currentDeclared() map[string]bool {
+	if len(resolver.scopes) > 0 {
+		return resolver.scopes[len(resolver.scopes)-1].declared
+	}
+	return resolver.declared
+}
+
+func (resolver *Resolver) exprs(exprs []Expr) []Expr {
+	resolved := make([]Expr, len(exprs))
+	for i, expr := range exprs {
+		resolved[i] = resolver.expr(expr)
+	}
+	return resolved
+}
+
+func (resolver *Resolver) optional(expr Expr) Expr {
+	if expr == nil {
+		return nil
+	}
+	return resolver.expr(expr)
+}
+
+func (resolver *Resolver) expr(expr Expr) Expr {
+	switch e := expr.(type) {
+	case LiteralExpr:
+		if e.Token.Type == IDENTIFIER {
+			e.Binding = resolver.resolve(e.Token, false)
+		}
+		return e
+	case AssignExpr:
+		e.Value = resolver.expr(e.Value)
+		if e.Operator.Type == COLON_EQ {
+			e.Binding = resolver.declare(e.Name)
+		} else {
+			e.Binding = resolver.resolve(e.Name, true)
+		}
+		return e
+	case BinaryExpr:
+		e.Left = resolver.expr(e.Left)
+		e.Right = resolver.expr(e.Right)
+		return e
+	case LogicalExpr:
+		e.Left = resolver.expr(e.Left)
+		e.Right = resolver.expr(e.Right)
+		return e
+	case UnaryExpr:
+		e.Operand = resolver.expr(e.Operand)
+		return e
+	case GroupingExpr:
+		e.Inner = resolver.expr(e.Inner)
+		return e
+	case BlockExpr:
+		scope := resolver.beginScope(e.Body, nil)
+		e.Body = resolver.exprs(e.Body)
+		e.Slots = scope.size
+		resolver.endScope()
+		return e
+	case IfExpr:
+		e.Condition = resolver.expr(e.Condition)
+
+		// Only one branch runs, so each may declare the same name once.
+		declared := resolver.currentDeclared()
+		before := maps.Clone(declared)
+		e.Then = resolver.expr(e.Then)
+		if e.Else != nil {
+			afterThen := maps.Clone(declared)
+			clear(declared)
+			maps.Copy(declared, before)
+			e.Else = resolver.expr(e.Else)
+			maps.Copy(declared, afterThen)
+		}
+		return e
+	case WhileExpr:
+		e.Condition = resolver.expr(e.Condition)
+		e.Body = resolver.expr(e.Body)
+		e.Increment = resolver.optional(e.Increment)
+		return e
+	case CallExpr:
+		e.Callee = resolver.expr(e.Callee)
+		e.Args = resolver.exprs(e.Args)
+		return e
+	case FnDeclExpr:
+		e.Body, e.Slots = resolver.function(e.Params, e.Body)
+		e.Binding = resolver.declare(e.Name)
+		return e
+	case FnExpr:
+		e.Body, e.Slots = resolver.function(e.Params, e.Body)
+		return e
+	case ArrayInitExpr:
+		e.Elements = resolver.exprs(e.Elements)
+		return e
+	case IndexExpr:
+		e.Object = resolver.expr(e.Object)
+		e.Index = resolver.expr(e.Index)
+		return e
+	case IndexAssignExpr:
+		e.Object = resolver.expr(e.Object)
+		e.Index = resolver.expr(e.Index)
+		e.Value = resolver.expr(e.Value)
+		return e
+	case MapInitExpr:
+		e.Keys = resolver.exprs(e.Keys)
+		e.Values = resolver.exprs(e.Values)
+		return e
+	case ReturnExpr:
+		e.Value = resolver.optional(e.Value)
+		return e
+	case ImportExpr:
+		resolver.declare(e.Alias())
+		return e
+	case GetExpr:
+		e.Object = resolver.expr(e.Object)
+		return e
+	default:
+		return expr
+	}
+}
+
+// function resolves a function body in a new scope that starts with the
+// parameters, and returns the body together with the size of that scope.
+func (resolver *Resolver) function(params []Token, body Expr) (Expr, int) {
+	resolver.fnDepth++
+	defer func() {
+		resolver.fnDepth--
+	}()
+
+	for i, param := range params {
+		for _, other := range params[:i] {
+			if other.String() == param.String() {
+				resolver.errorAt(param, "Duplicate parameter %s", param.String())
+			}
+		}
+	}
+
+	scope := resolver.beginScope([]Expr{body}, params)
+	body = resolver.expr(body)
+	resolver.endScope()
+	return body, scope.size
+}
@@ -0,0 +1,78 @@
+package core
+
+import "testing"
+
+func TestResolveBindings(t *testing.T) {
+	program := parse(t, `fn f(a) {
+  b := a
+  { c := b return fn() a + c }
+}`)
+	resolver := CreateResolver(NewInterpreter().Globals())
+	program, err := resolver.Resolve(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := program[0].(FnDeclExpr).Body.(BlockExpr).Body
+	inner := body[1].(BlockExpr).Body
+	closure := inner[1].(ReturnExpr).Value.(FnExpr).Body.(BinaryExpr)
+	tests := []struct {
+		name string
+		expr Expr
+		want Binding
+	}{
+		{"parameter", body[0].(AssignExpr).Value, Binding{Depth: 1, Slot: 0}},
+		{"local", body[0], Binding{Depth: 0, Slot: 0}},
+		{"block local", inner[0].(AssignExpr).Value, Binding{Depth: 1, Slot: 0}},
+		{"captured parameter", closure.Left, Binding{Depth: 3, Slot: 0}},
+		{"captured block local", closure.Right, Binding{Depth: 1, Slot: 0}},
+	}
+
+	for _, test := range tests {
+		var binding *Binding
+		switch expr := test.expr.(type) {
+		case LiteralExpr:
+			binding = expr.Binding
+		case AssignExpr:
+			binding = expr.Binding
+		}
+		if binding == nil || *binding != test.want {
+			t.Errorf("%s: Binding is %v, want %+v", test.name, binding, test.want)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	runPrograms(t, []programTest{
+		{
+			name: "shadowing",
+			src:  `{ a := 1 { a := 2 print(a) } print(a) }`,
+			out:  "2\n1\n",
+		},
+		{
+			name: "global declared after the function",
+			src:  `fn f() { return w } w := 3 print(f())`,
+			out:  "3\n",
+		},
+		{
+			name: "redeclared",
+			src:  "x := 1\nx := 2",
+			err:  "[ERROR] Variable x is already declared in this scope at Line 2, Column 1",
+		},
+		{
+			name: "used before declaration",
+			src:  `fn f() { print(y) y := 1 }`,
+			err:  "[ERROR] Variable y used before declaration at Line 1, Column 16",
+		},
+		{
+			name: "undeclared",
+			src:  `print(nope)`,
+			err:  "[ERROR] Undeclared variable nope at Line 1, Column 7",
+		},
+		{
+			name: "assign to undeclared",
+			src:  `z = 1`,
+			err:  "[ERROR] Cannot assign to undeclared variable z at Line 1, Column 1",
+		},
+	})
+}
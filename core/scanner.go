@@ -2,7 +2,6 @@ package core
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"unicode"
 )
@@ -16,18 +15,26 @@ func init() {
 	KEYWORDS["else"] = ELSE
 	KEYWORDS["if"] = IF
 	KEYWORDS["return"] = RETURN
+	KEYWORDS["break"] = BREAK
+	KEYWORDS["continue"] = CONTINUE
+	KEYWORDS["import"] = IMPORT
 	KEYWORDS["true"] = TRUE
 	KEYWORDS["false"] = FALSE
   KEYWORDS["fn"] = FUNCTION
 }
 
 type Scanner struct {
-	Source  []rune
-	Tokens  []Token
-	Start   int
-	Current int
-	Line    int
-	Column  int
+	Source      []rune
+	Tokens      []Token
+	Comments    []Comment
+	Errors      []error
+	File        string
+	Start       int
+	Current     int
+	StartLine   int
+	StartColumn int
+	Line        int
+	Column      int
 }
 
 func CreateScanner(source string) Scanner {
@@ -41,17 +48,41 @@ func CreateScanner(source string) Scanner {
 }
 
 func (scanner *Scanner) AddToken(token TokenType) {
-	scanner.Tokens = append(scanner.Tokens, Token{
-		Type: token,
-		Line: scanner.Line,
-	})
+	scanner.AddTokenWithValue(token, nil)
 }
 
 func (scanner *Scanner) AddTokenWithValue(token TokenType, value TokenValue) {
 	scanner.Tokens = append(scanner.Tokens, Token{
-		Type:  token,
-		Line:  scanner.Line,
-		Value: value,
+		Type:   token,
+		Line:   scanner.StartLine,
+		Column: scanner.StartColumn,
+		Length: scanner.Current - scanner.Start,
+		File:   scanner.File,
+		Value:  value,
+	})
+}
+
+func (scanner *Scanner) AddComment() {
+	scanner.Comments = append(scanner.Comments, Comment{
+		Span: Span{
+			File:   scanner.File,
+			Line:   scanner.StartLine,
+			Column: scanner.StartColumn,
+			Length: scanner.Current - scanner.Start,
+		},
+		Text: string(scanner.Source[scanner.Start:scanner.Current]),
+	})
+}
+
+func (scanner *Scanner) AddError(message string) {
+	scanner.Errors = append(scanner.Errors, &ScanError{
+		Span: Span{
+			File:   scanner.File,
+			Line:   scanner.StartLine,
+			Column: scanner.StartColumn,
+			Length: scanner.Current - scanner.Start,
+		},
+		Message: message,
 	})
 }
 
@@ -112,6 +143,7 @@ func (scanner *Scanner) ScanToken() {
 			for !scanner.CurrentAtEnd() && scanner.PeekCurrent() != '\n' {
 				scanner.Advance()
 			}
+			scanner.AddComment()
 		} else if scanner.PeekCurrent() == rune('=') {
 			scanner.Advance()
 			scanner.AddToken(SLASH_EQ)
@@ -210,8 +242,7 @@ func (scanner *Scanner) ScanToken() {
 		} else if unicode.IsLetter(c) {
 			scanner.ScanIdentifier()
 		} else {
-			fmt.Printf("[ERROR] Unexpected Character at Line %d, Column %d\n", scanner.Line, scanner.Column)
-			os.Exit(1)
+			scanner.AddError(fmt.Sprintf("Unexpected Character %q", c))
 		}
 	}
 }
@@ -237,11 +268,14 @@ func (scanner *Scanner) ScanNumber() {
 
 func (scanner *Scanner) ScanString() {
 	for !scanner.CurrentAtEnd() && scanner.PeekCurrent() != rune('"') {
-		scanner.Advance()
+		if scanner.Advance() == rune('\n') {
+			scanner.Line++
+			scanner.Column = 1
+		}
 	}
 
 	if scanner.CurrentAtEnd() {
-		fmt.Printf("[ERROR] Unterminated String at Line %d, Column %d\n", scanner.Line, scanner.Column)
+		scanner.AddError("Unterminated String")
 		return
 	}
 
@@ -282,14 +316,25 @@ func (scanner Scanner) IsAtEnd(toCheck int) bool {
 	return toCheck >= len(scanner.Source)
 }
 
-func (scanner *Scanner) ScanTokens() {
+// ScanTokens scans the whole source, skipping over characters it cannot
+// scan. All problems are collected in Errors and returned as an ErrorList.
+func (scanner *Scanner) ScanTokens() error {
 	for !scanner.CurrentAtEnd() {
 		scanner.Start = scanner.Current
+		scanner.StartLine = scanner.Line
+		scanner.StartColumn = scanner.Column
 		scanner.ScanToken()
 	}
 
 	scanner.Tokens = append(scanner.Tokens, Token{
-		Type: EOF,
-		Line: scanner.Line,
+		Type:   EOF,
+		Line:   scanner.Line,
+		Column: scanner.Column,
+		File:   scanner.File,
 	})
+
+	if len(scanner.Errors) > 0 {
+		return ErrorList(scanner.Errors)
+	}
+	return nil
 }
@@ -0,0 +1,20 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScanRecovery(t *testing.T) {
+	scanner := CreateScanner("x := @\ny := \"abc")
+	err := scanner.ScanTokens()
+
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Fatalf("Got %v, want two errors", err)
+	}
+	var scanErr *ScanError
+	if !errors.As(list[1], &scanErr) || scanErr.Message != "Unterminated String" {
+		t.Errorf("Second error is %v, want an unterminated string", list[1])
+	}
+}
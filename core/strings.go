@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// stringsModule returns the members of the strings module. Positions and
+// lengths count runes, not bytes, like indexing a String does.
+func stringsModule() map[string]any {
+	return map[string]any{
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"contains":   strings.Contains,
+		"startsWith": strings.HasPrefix,
+		"endsWith":   strings.HasSuffix,
+		"replace":    strings.ReplaceAll,
+		"split": func(s string, sep string) []string {
+			return strings.Split(s, sep)
+		},
+		"join": func(elements []any, sep string) string {
+			parts := make([]string, len(elements))
+			for i, element := range elements {
+				parts[i] = stringify(element)
+			}
+			return strings.Join(parts, sep)
+		},
+		"repeat": func(s string, count int) (string, error) {
+			if count < 0 {
+				return "", fmt.Errorf("Negative repeat count %d", count)
+			}
+			return strings.Repeat(s, count), nil
+		},
+		"find": func(s string, substr string) int {
+			i := strings.Index(s, substr)
+			if i < 0 {
+				return -1
+			}
+			return utf8.RuneCountInString(s[:i])
+		},
+		"slice": func(s string, start int, end int) (string, error) {
+			runes := []rune(s)
+			if start < 0 || end < start || end > len(runes) {
+				return "", fmt.Errorf("Slice [%d:%d] out of range for String of length %d", start, end, len(runes))
+			}
+			return string(runes[start:end]), nil
+		},
+
+		"toString": func(v any) string {
+			return stringify(v)
+		},
+		"format": func(x float64, digits int) (string, error) {
+			if digits < 0 {
+				return "", fmt.Errorf("Negative digit count %d", digits)
+			}
+			return strconv.FormatFloat(x, 'f', digits, 64), nil
+		},
+		"parseInt": func(s string) (int64, error) {
+			n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("Cannot parse %q as Int", s)
+			}
+			return n, nil
+		},
+		"parseFloat": func(s string) (float64, error) {
+			x, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return 0, fmt.Errorf("Cannot parse %q as Float", s)
+			}
+			return x, nil
+		},
+
+		"chr": func(code int64) (string, error) {
+			if code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("Invalid character code %d", code)
+			}
+			return string(rune(code)), nil
+		},
+		"ord": func(s string) (int64, error) {
+			if utf8.RuneCountInString(s) != 1 {
+				return 0, fmt.Errorf("Expected a single character, got %q", s)
+			}
+			r, _ := utf8.DecodeRuneInString(s)
+			return int64(r), nil
+		},
+	}
+}
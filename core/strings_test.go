@@ -0,0 +1,42 @@
+package core
+
+import "testing"
+
+func TestStringIndexing(t *testing.T) {
+	evalValues(t, `s := "¡Hola, señor!"`, []valueTest{
+		{src: `#s`, want: int64(13)},
+		{src: `s[0]`, want: "¡"},
+		{src: `s[10]`, want: "o"},
+		{src: `#""`, want: int64(0)},
+		{src: `s[13]`, err: "[ERROR] Index 13 out of range for String of length 13 at Line 1, Column 23"},
+		{src: `s[-1]`, err: "[ERROR] Index -1 out of range for String of length 13 at Line 1, Column 23"},
+		{src: `s[0] = "x"`, err: "[ERROR] Expected Array or Map as assignment target, got String at Line 1, Column 27"},
+	})
+}
+
+func TestStringsModule(t *testing.T) {
+	evalValues(t, `import "strings"`, []valueTest{
+		{src: `strings.upper("señor")`, want: "SEÑOR"},
+		{src: `strings.trim("  a b ")`, want: "a b"},
+		{src: `strings.contains("lagn", "ag")`, want: true},
+		{src: `strings.replace("a-b-c", "-", "+")`, want: "a+b+c"},
+		{src: `#strings.split("a,b,,c", ",")`, want: int64(4)},
+		{src: `strings.join([1, "b", 2.5], ", ")`, want: "1, b, 2.5"},
+		{src: `strings.repeat("ab", 3)`, want: "ababab"},
+		{src: `strings.find("señor", "o")`, want: int64(3)},
+		{src: `strings.find("señor", "x")`, want: int64(-1)},
+		{src: `strings.slice("señor", 1, 3)`, want: "eñ"},
+		{src: `strings.toString([1, "a"])`, want: `[1, "a"]`},
+		{src: `strings.format(2.0 / 3, 3)`, want: "0.667"},
+		{src: `strings.parseInt(" 41 ") + 1`, want: int64(42)},
+		{src: `strings.parseFloat("1.5")`, want: 1.5},
+		{src: `strings.chr(241)`, want: "ñ"},
+		{src: `strings.ord("ñ")`, want: int64(241)},
+		{src: `strings.repeat("a", -1)`, err: "[ERROR] Negative repeat count -1 at Line 1, Column 32"},
+		{src: `strings.slice("abc", 2, 1)`, err: "[ERROR] Slice [2:1] out of range for String of length 3 at Line 1, Column 31"},
+		{src: `strings.parseInt("x")`, err: "[ERROR] Cannot parse \"x\" as Int at Line 1, Column 34"},
+		{src: `strings.chr(-1)`, err: "[ERROR] Invalid character code -1 at Line 1, Column 29"},
+		{src: `strings.ord("ab")`, err: "[ERROR] Expected a single character, got \"ab\" at Line 1, Column 29"},
+		{src: `strings.upper(1)`, err: "[ERROR] Argument 1 of strings.upper: Expected String, got Int at Line 1, Column 31"},
+	})
+}
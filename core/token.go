@@ -66,6 +66,9 @@ const (
 	IF
 	ELSE
 	RETURN
+	BREAK
+	CONTINUE
+	IMPORT
 	FUNCTION
 
 	TRUE
@@ -86,7 +89,7 @@ var tokenTypeNames = [...]string{
 	"CIRCUM", "CIRCUM_EQ", "CIRCUM_CIRCUM", "CIRCUM_CIRCUM_EQ",
 	"BANG", "BANG_EQ", "EQUAL", "EQUAL_EQ", "GREATER", "GREATER_EQ", "LESS", "LESS_EQ",
 	"IDENTIFIER", "STRING", "NUMBER",
-	"FOR", "WHILE", "IF", "ELSE", "RETURN", "FUNCTION",
+	"FOR", "WHILE", "IF", "ELSE", "RETURN", "BREAK", "CONTINUE", "IMPORT", "FUNCTION",
 	"TRUE", "FALSE",
 	"UNKOWN",
 	"EOF",
@@ -103,9 +106,21 @@ func init() {
 }
 
 type Token struct {
-	Type  TokenType
-	Value TokenValue
-	Line  int
+	Type   TokenType
+	Value  TokenValue
+	Line   int
+	Column int
+	Length int
+	File   string
+}
+
+func (token Token) Span() Span {
+	return Span{
+		File:   token.File,
+		Line:   token.Line,
+		Column: token.Column,
+		Length: token.Length,
+	}
 }
 
 func (token Token) String() string {
@@ -116,7 +131,14 @@ func (token Token) String() string {
 		return fmt.Sprintf("%q", token.Value.(string))
 	}
 	if token.Type == NUMBER {
-		return fmt.Sprintf("%v", token.Value.(float64))
+		return fmt.Sprintf("%v", token.Value)
 	}
 	return token.Value.(string)
 }
+
+// Comment is a '//' comment. The scanner keeps comments apart from the
+// tokens, only tools like the formatter look at them.
+type Comment struct {
+	Span Span
+	Text string
+}
@@ -0,0 +1,157 @@
+package core
+
+// Walk calls visit with expr and then, as long as visit returns true, with
+// each of its children in turn, depth first.
+func Walk(expr Expr, visit func(Expr) bool) {
+	if expr == nil || !visit(expr) {
+		return
+	}
+	walk := func(exprs ...Expr) {
+		for _, e := range exprs {
+			Walk(e, visit)
+		}
+	}
+
+	switch e := expr.(type) {
+	case AssignExpr:
+		walk(e.Value)
+	case BinaryExpr:
+		walk(e.Left, e.Right)
+	case LogicalExpr:
+		walk(e.Left, e.Right)
+	case UnaryExpr:
+		walk(e.Operand)
+	case GroupingExpr:
+		walk(e.Inner)
+	case BlockExpr:
+		walk(e.Body...)
+	case IfExpr:
+		walk(e.Condition, e.Then, e.Else)
+	case WhileExpr:
+		walk(e.Condition, e.Body, e.Increment)
+	case CallExpr:
+		walk(e.Callee)
+		walk(e.Args...)
+	case FnDeclExpr:
+		walk(e.Body)
+	case FnExpr:
+		walk(e.Body)
+	case ArrayInitExpr:
+		walk(e.Elements...)
+	case IndexExpr:
+		walk(e.Object, e.Index)
+	case IndexAssignExpr:
+		walk(e.Object, e.Index, e.Value)
+	case MapInitExpr:
+		for i := range e.Keys {
+			walk(e.Keys[i], e.Values[i])
+		}
+	case GetExpr:
+		walk(e.Object)
+	case ReturnExpr:
+		walk(e.Value)
+	}
+}
+
+// WalkTokens calls f with every token stored in expr and its children.
+// Tokens the parser did not fill in, like the braces of
+// blocks it creates itself, are passed as the zero Token.
+func WalkTokens(expr Expr, f func(Token)) {
+	walk := func(exprs ...Expr) {
+		for _, e := range exprs {
+			if e != nil {
+				WalkTokens(e, f)
+			}
+		}
+	}
+
+	switch e := expr.(type) {
+	case LiteralExpr:
+		f(e.Token)
+	case AssignExpr:
+		f(e.Name)
+		f(e.Operator)
+		walk(e.Value)
+	case BinaryExpr:
+		walk(e.Left)
+		f(e.Operator)
+		walk(e.Right)
+	case LogicalExpr:
+		walk(e.Left)
+		f(e.Operator)
+		walk(e.Right)
+	case UnaryExpr:
+		f(e.Operator)
+		walk(e.Operand)
+	case GroupingExpr:
+		walk(e.Inner)
+	case BlockExpr:
+		f(e.Brace)
+		walk(e.Body...)
+		f(e.RightBrace)
+	case IfExpr:
+		f(e.Keyword)
+		walk(e.Condition, e.Then, e.Else)
+	case WhileExpr:
+		f(e.Label)
+		f(e.Keyword)
+		walk(e.Condition, e.Body, e.Increment)
+	case CallExpr:
+		walk(e.Callee)
+		f(e.Paren)
+		walk(e.Args...)
+		f(e.RightParen)
+	case FnDeclExpr:
+		f(e.Keyword)
+		f(e.Name)
+		f(e.Paren)
+		for _, param := range e.Params {
+			f(param)
+		}
+		f(e.RightParen)
+		walk(e.Body)
+	case FnExpr:
+		f(e.Keyword)
+		f(e.Paren)
+		for _, param := range e.Params {
+			f(param)
+		}
+		f(e.RightParen)
+		walk(e.Body)
+	case ArrayInitExpr:
+		f(e.Bracket)
+		walk(e.Elements...)
+		f(e.RightBracket)
+	case IndexExpr:
+		walk(e.Object)
+		f(e.Bracket)
+		walk(e.Index)
+	case IndexAssignExpr:
+		walk(e.Object, e.Index)
+		f(e.Operator)
+		walk(e.Value)
+	case MapInitExpr:
+		f(e.Brace)
+		for i := range e.Keys {
+			walk(e.Keys[i], e.Values[i])
+		}
+		f(e.RightBrace)
+	case ImportExpr:
+		f(e.Keyword)
+		f(e.Name)
+		f(e.Path)
+	case GetExpr:
+		walk(e.Object)
+		f(e.Dot)
+		f(e.Name)
+	case ReturnExpr:
+		f(e.Keyword)
+		walk(e.Value)
+	case BreakExpr:
+		f(e.Keyword)
+		f(e.Label)
+	case ContinueExpr:
+		f(e.Keyword)
+		f(e.Label)
+	}
+}
@@ -0,0 +1,395 @@
+// Package editor reads lines from a terminal with basic line editing and
+// history, without depending on anything outside the standard library.
+//
+// When the input is not a terminal, or the terminal cannot be put into
+// raw mode, lines are read as they come and no editing is offered.
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C.
+var ErrInterrupted = errors.New("Interrupted")
+
+// MaxHistory is the number of history entries kept in memory and in the
+// history file.
+const MaxHistory = 1000
+
+// Completion is a candidate for the word before the cursor.
+type Completion struct {
+	// Text replaces the word when the completion is chosen.
+	Text string
+	// Hint is shown after Text when candidates are listed, e.g. the
+	// parameters of a function.
+	Hint string
+}
+
+// Completer returns the candidates for the word that ends at cursor in
+// line and the index that word starts at.
+type Completer func(line []rune, cursor int) (start int, completions []Completion)
+
+// Editor reads lines from in and echoes them to out.
+type Editor struct {
+	// Complete is called when Tab is pressed. A single candidate replaces
+	// the word, several extend it to their longest common prefix, or are
+	// listed when the word cannot be extended.
+	Complete Completer
+
+	in     *os.File
+	out    io.Writer
+	reader *bufio.Reader
+
+	history     []string
+	historyFile string
+}
+
+func New(in *os.File, out io.Writer) *Editor {
+	return &Editor{
+		in:     in,
+		out:    out,
+		reader: bufio.NewReader(in),
+	}
+}
+
+// IsTerminal reports whether lines are read from an interactive terminal.
+func (e *Editor) IsTerminal() bool {
+	return isTerminal(e.in.Fd())
+}
+
+// LoadHistory reads the entries saved in the file at path and appends
+// every following AddHistory to it. A missing file is not an error.
+func (e *Editor) LoadHistory(path string) error {
+	e.historyFile = path
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) > MaxHistory {
+		lines = lines[len(lines)-MaxHistory:]
+		// Truncate the file so it does not grow forever.
+		err = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	}
+	for _, line := range lines {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	return err
+}
+
+// AddHistory records line as the most recent entry. Blank lines and
+// repetitions of the previous entry are skipped.
+func (e *Editor) AddHistory(line string) error {
+	if strings.TrimSpace(line) == "" || strings.ContainsRune(line, '\n') {
+		return nil
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return nil
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile == "" {
+		return nil
+	}
+	file, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, line)
+	return errors.Join(err, file.Close())
+}
+
+// History returns the recorded entries, oldest first.
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// ReadLine shows prompt and returns the line the user entered, without
+// its line ending. It returns io.EOF once the input is exhausted or the
+// user presses Ctrl-D on an empty line.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.IsTerminal() {
+		state, err := makeRaw(e.in.Fd())
+		if err == nil {
+			defer restore(e.in.Fd(), state)
+			return e.edit(prompt)
+		}
+	}
+
+	fmt.Fprint(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), err
+}
+
+// line is the state of the line being edited.
+type line struct {
+	prompt string
+	buffer []rune
+	cursor int
+}
+
+// edit reads keys in raw mode until the line is entered.
+func (e *Editor) edit(prompt string) (string, error) {
+	l := &line{prompt: prompt}
+	// Browsing the history starts below the newest entry, where the line
+	// being typed is kept.
+	index := len(e.history)
+	pending := ""
+
+	browse := func(to int) {
+		if to < 0 || to > len(e.history) || to == index {
+			return
+		}
+		if index == len(e.history) {
+			pending = string(l.buffer)
+		}
+		index = to
+		if index == len(e.history) {
+			l.buffer = []rune(pending)
+		} else {
+			l.buffer = []rune(e.history[index])
+		}
+		l.cursor = len(l.buffer)
+	}
+
+	e.refresh(l)
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(l.buffer), nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrl('D'):
+			if len(l.buffer) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.delete(l.cursor, l.cursor+1)
+		case 127, ctrl('H'):
+			l.delete(l.cursor-1, l.cursor)
+		case ctrl('A'):
+			l.cursor = 0
+		case ctrl('E'):
+			l.cursor = len(l.buffer)
+		case ctrl('B'):
+			l.cursor = max(l.cursor-1, 0)
+		case ctrl('F'):
+			l.cursor = min(l.cursor+1, len(l.buffer))
+		case ctrl('K'):
+			l.delete(l.cursor, len(l.buffer))
+		case ctrl('U'):
+			l.delete(0, l.cursor)
+		case ctrl('W'):
+			l.delete(l.wordStart(), l.cursor)
+		case ctrl('P'):
+			browse(index - 1)
+		case ctrl('N'):
+			browse(index + 1)
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case '\t':
+			e.complete(l)
+		case 27:
+			switch e.escape() {
+			case "[A", "OA":
+				browse(index - 1)
+			case "[B", "OB":
+				browse(index + 1)
+			case "[C", "OC":
+				l.cursor = min(l.cursor+1, len(l.buffer))
+			case "[D", "OD":
+				l.cursor = max(l.cursor-1, 0)
+			case "[H", "OH", "[1~", "[7~":
+				l.cursor = 0
+			case "[F", "OF", "[4~", "[8~":
+				l.cursor = len(l.buffer)
+			case "[3~":
+				l.delete(l.cursor, l.cursor+1)
+			case "b":
+				l.cursor = l.wordStart()
+			case "f":
+				l.cursor = l.wordEnd()
+			}
+		default:
+			if unicode.IsPrint(r) {
+				l.insert(r)
+			}
+		}
+		e.refresh(l)
+	}
+}
+
+func (e *Editor) complete(l *line) {
+	if e.Complete == nil {
+		return
+	}
+	start, completions := e.Complete(l.buffer, l.cursor)
+	if len(completions) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+
+	prefix := []rune(completions[0].Text)
+	for _, completion := range completions[1:] {
+		prefix = commonPrefix(prefix, []rune(completion.Text))
+	}
+	if len(completions) == 1 || len(prefix) > l.cursor-start {
+		l.delete(start, l.cursor)
+		for _, r := range prefix {
+			l.insert(r)
+		}
+		return
+	}
+	e.list(completions)
+}
+
+// list prints completions in columns below the line, refresh draws the
+// line again underneath.
+func (e *Editor) list(completions []Completion) {
+	const lineWidth = 80
+
+	width := 0
+	for _, completion := range completions {
+		width = max(width, len([]rune(completion.Text+completion.Hint)))
+	}
+	width += 2
+	columns := max(lineWidth/width, 1)
+
+	var b strings.Builder
+	b.WriteString("\r\n")
+	for i, completion := range completions {
+		item := completion.Text + completion.Hint
+		if (i+1)%columns == 0 || i == len(completions)-1 {
+			b.WriteString(item)
+			b.WriteString("\r\n")
+		} else {
+			b.WriteString(item)
+			b.WriteString(strings.Repeat(" ", width-len([]rune(item))))
+		}
+	}
+	io.WriteString(e.out, b.String())
+}
+
+func commonPrefix(a []rune, b []rune) []rune {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return a[:n]
+}
+
+// escape reads the rest of an escape sequence after ESC, e.g. "[A" for
+// the up arrow or "b" for Alt-b.
+func (e *Editor) escape() string {
+	first, _, err := e.reader.ReadRune()
+	if err != nil {
+		return ""
+	}
+	if first != '[' && first != 'O' {
+		return string(first)
+	}
+
+	sequence := []rune{first}
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return ""
+		}
+		sequence = append(sequence, r)
+		// Parameters are digits and ';', the sequence ends with the
+		// first character of any other kind.
+		if (r < '0' || r > '9') && r != ';' {
+			return string(sequence)
+		}
+	}
+}
+
+// refresh redraws the prompt and the line and places the cursor. Every
+// rune is assumed to take a single column.
+func (e *Editor) refresh(l *line) {
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(l.prompt)
+	b.WriteString(string(l.buffer))
+	b.WriteString("\x1b[K")
+	if back := len(l.buffer) - l.cursor; back > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+func (l *line) insert(r rune) {
+	l.buffer = append(l.buffer, 0)
+	copy(l.buffer[l.cursor+1:], l.buffer[l.cursor:])
+	l.buffer[l.cursor] = r
+	l.cursor++
+}
+
+// delete removes the runes in [from, to), clamped to the buffer, and
+// moves the cursor to from.
+func (l *line) delete(from int, to int) {
+	from = max(from, 0)
+	to = min(to, len(l.buffer))
+	if from >= to {
+		return
+	}
+	l.buffer = append(l.buffer[:from], l.buffer[to:]...)
+	l.cursor = from
+}
+
+// wordStart returns the start of the word before the cursor.
+func (l *line) wordStart() int {
+	i := l.cursor
+	for i > 0 && !isWordRune(l.buffer[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(l.buffer[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor.
+func (l *line) wordEnd() int {
+	i := l.cursor
+	for i < len(l.buffer) && !isWordRune(l.buffer[i]) {
+		i++
+	}
+	for i < len(l.buffer) && isWordRune(l.buffer[i]) {
+		i++
+	}
+	return i
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
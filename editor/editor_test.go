@@ -0,0 +1,207 @@
+package editor
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pipe returns an Editor reading input from a pipe, which is never a
+// terminal, and the buffer its output goes to.
+func pipe(t *testing.T, input string) (*Editor, *strings.Builder) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	go func() {
+		io.WriteString(w, input)
+		w.Close()
+	}()
+
+	var out strings.Builder
+	return New(r, &out), &out
+}
+
+func TestReadLine(t *testing.T) {
+	e, out := pipe(t, "first\r\nsecond\nlast")
+	if e.IsTerminal() {
+		t.Fatal("A pipe is a terminal")
+	}
+
+	for _, want := range []string{"first", "second", "last"} {
+		line, err := e.ReadLine("> ")
+		if err != nil || line != want {
+			t.Errorf("Got %q, %v, want %q", line, err, want)
+		}
+	}
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("Got %v at the end of the input, want io.EOF", err)
+	}
+	if out.String() != "> > > > " {
+		t.Errorf("Prompted %q", out.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte("old\n\nolder\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e, _ := pipe(t, "")
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"a", "a", " ", "multi\nline", "b"} {
+		if err := e.AddHistory(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := "old,older,a,b"
+	if got := strings.Join(e.History(), ","); got != want {
+		t.Errorf("History is %s, want %s", got, want)
+	}
+
+	// Entries are appended to the file and read back by the next session.
+	next, _ := pipe(t, "")
+	if err := next.LoadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(next.History(), ","); got != want {
+		t.Errorf("Loaded history is %s, want %s", got, want)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var lines []string
+	for i := 0; i < MaxHistory+10; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	e, _ := pipe(t, "")
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.History()) != MaxHistory || e.History()[0] != lines[10] {
+		t.Errorf("Kept %d entries starting with %d characters", len(e.History()), len(e.History()[0]))
+	}
+
+	// The file is truncated to the entries that were kept.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(content), "\n"); got != MaxHistory {
+		t.Errorf("History file has %d lines, want %d", got, MaxHistory)
+	}
+}
+
+func TestMissingHistory(t *testing.T) {
+	e, _ := pipe(t, "")
+	if err := e.LoadHistory(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Loading a missing history file failed: %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	words := []Completion{{Text: "print", Hint: "(1)"}, {Text: "printAll", Hint: "(2)"}, {Text: "while"}}
+	complete := func(line []rune, cursor int) (int, []Completion) {
+		start := cursor
+		for start > 0 && line[start-1] != ' ' {
+			start--
+		}
+		var completions []Completion
+		for _, word := range words {
+			if strings.HasPrefix(word.Text, string(line[start:cursor])) {
+				completions = append(completions, word)
+			}
+		}
+		return start, completions
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		out   string
+	}{
+		{"single candidate", "x wh\t\r", "x while", ""},
+		{"common prefix", "pr\t\r", "print", ""},
+		{"before the cursor", "pr(1)\x02\x02\x02\t\r", "print(1)", ""},
+		{"listed", "print\t\r", "print", "print(1)     printAll(2)\r\n"},
+		{"no candidate", "zz\t\r", "zz", "\a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, out := pipe(t, test.input)
+			e.Complete = complete
+			line, err := e.edit("> ")
+			if err != nil || line != test.want {
+				t.Errorf("Got %q, %v, want %q", line, err, test.want)
+			}
+			if !strings.Contains(out.String(), test.out) {
+				t.Errorf("Output %q does not contain %q", out.String(), test.out)
+			}
+		})
+	}
+}
+
+func TestEditKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"insert at the cursor", "ac\x02b\r", "abc"},
+		{"backspace", "abc\x7f\r", "ab"},
+		{"kill to the end", "abc\x01\x06\x0b\r", "a"},
+		{"kill to the start", "abc\x02\x15\r", "c"},
+		{"delete a word", "one two\x17\r", "one "},
+		{"arrow keys", "ac\x1b[Db\x1b[C\r", "abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, _ := pipe(t, test.input)
+			line, err := e.edit("> ")
+			if err != nil || line != test.want {
+				t.Errorf("Got %q, %v, want %q", line, err, test.want)
+			}
+		})
+	}
+}
+
+func TestEditHistory(t *testing.T) {
+	e, _ := pipe(t, "new\x1b[A\x1b[A\x1b[B\r")
+	e.history = []string{"first", "second"}
+	line, err := e.edit("> ")
+	if err != nil || line != "second" {
+		t.Errorf("Got %q, %v, want second", line, err)
+	}
+
+	// Browsing back down restores the line being typed.
+	e, _ = pipe(t, "new\x10\x0e\r")
+	e.history = []string{"first"}
+	line, err = e.edit("> ")
+	if err != nil || line != "new" {
+		t.Errorf("Got %q, %v, want new", line, err)
+	}
+
+	e, _ = pipe(t, "\x03")
+	if _, err := e.edit("> "); err != ErrInterrupted {
+		t.Errorf("Ctrl-C gave %v, want ErrInterrupted", err)
+	}
+	e, _ = pipe(t, "\x04")
+	if _, err := e.edit("> "); err != io.EOF {
+		t.Errorf("Ctrl-D gave %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package editor
+
+import "errors"
+
+// Raw mode is only implemented for Unix terminals, everywhere else lines
+// are read without editing.
+
+type termState struct{}
+
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (*termState, error) {
+	return nil, errors.New("Raw mode is not supported on this platform")
+}
+
+func restore(fd uintptr, state *termState) error {
+	return nil
+}
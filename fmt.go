@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+// runFmt implements "lagn fmt". Without files it formats stdin to stdout.
+// It returns the exit code of the process, 1 when --check finds a file
+// that is not formatted.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list files whose formatting differs and exit with status 1 if there are any")
+	write := flags.Bool("write", false, "write the result to the files instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: lagn fmt [--check | --write] [files...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 64
+	}
+	if *check && *write {
+		fmt.Fprintln(os.Stderr, "--check and --write cannot be used together")
+		return 64
+	}
+
+	if flags.NArg() == 0 {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 66
+		}
+		return formatFile("<stdin>", string(content), *check, false)
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = max(status, 66)
+			continue
+		}
+		status = max(status, formatFile(path, string(content), *check, *write))
+	}
+	return status
+}
+
+// formatFile formats the source of the file at path and prints it, lists
+// the file in check mode if it is not formatted, or rewrites it.
+func formatFile(path string, src string, check bool, write bool) int {
+	formatted, err := core.Format(path, src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, core.FormatError(err, src))
+		return 65
+	}
+
+	switch {
+	case check:
+		if formatted != src {
+			fmt.Println(path)
+			return 1
+		}
+	case write:
+		if formatted != src {
+			if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 74
+			}
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return 0
+}
@@ -0,0 +1,530 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+// document is an open text document and what the server found out about
+// it the last time it changed.
+type document struct {
+	uri   string
+	text  string
+	lines [][]rune
+
+	tokens       []core.Token
+	program      []core.Expr
+	references   []core.Reference
+	declarations []declaration
+	diagnostics  []Diagnostic
+}
+
+// declaration is a name the document declares.
+type declaration struct {
+	name core.Token
+	kind int
+	// params is set for functions, detail shows how the name is declared.
+	params []core.Token
+	detail string
+	// scope is the function the name is visible in, nil for top-level
+	// names.
+	scope *Range
+}
+
+// newDocument scans, parses and resolves text. Everything that parsed is
+// analyzed even when there are errors, so features keep working while a
+// file is being edited. Names are resolved against globals.
+func newDocument(uri string, text string, globals *core.Environment) *document {
+	doc := &document{
+		uri:         uri,
+		text:        text,
+		diagnostics: []Diagnostic{},
+	}
+	for _, line := range strings.Split(text, "\n") {
+		doc.lines = append(doc.lines, []rune(line))
+	}
+
+	scanner := core.CreateScanner(text)
+	scanErr := scanner.ScanTokens()
+	parser := core.CreateParser(scanner.Tokens)
+	program, parseErr := parser.Parse()
+	resolver := core.CreateResolver(globals)
+	_, resolveErr := resolver.Resolve(program)
+
+	doc.tokens = scanner.Tokens
+	doc.program = program
+	doc.references = resolver.References()
+	for _, expr := range program {
+		doc.declare(expr, nil)
+	}
+
+	// Names in a program that does not parse are often only missing
+	// because the statement declaring them is broken.
+	err := errors.Join(scanErr, parseErr)
+	if err == nil {
+		err = resolveErr
+	}
+	doc.diagnose(err)
+	return doc
+}
+
+// diagnose adds a Diagnostic for err and every error it wraps.
+func (doc *document) diagnose(err error) {
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, inner := range multi.Unwrap() {
+			doc.diagnose(inner)
+		}
+		return
+	}
+	if err == nil {
+		return
+	}
+
+	message := err.Error()
+	switch err := err.(type) {
+	case *core.ScanError:
+		message = err.Message
+	case *core.ParseError:
+		message = err.Message
+	case *core.ResolveError:
+		message = err.Message
+	}
+	span, _ := core.ErrorSpan(err)
+	doc.diagnostics = append(doc.diagnostics, Diagnostic{
+		Range:    doc.rangeOf(span),
+		Severity: severityError,
+		Source:   "lagn",
+		Message:  message,
+	})
+}
+
+// declare records the declarations in expr, which runs in the function
+// spanning scope.
+func (doc *document) declare(expr core.Expr, scope *Range) {
+	add := func(name core.Token, kind int, params []core.Token, detail string, scope *Range) {
+		doc.declarations = append(doc.declarations, declaration{
+			name:   name,
+			kind:   kind,
+			params: params,
+			detail: detail,
+			scope:  scope,
+		})
+	}
+	function := func(params []core.Token, body core.Expr, self core.Expr) {
+		inner := doc.rangeOfExpr(self)
+		for _, param := range params {
+			add(param, completionVariable, nil, "parameter "+param.String(), &inner)
+		}
+		doc.declare(body, &inner)
+	}
+
+	core.Walk(expr, func(expr core.Expr) bool {
+		switch e := expr.(type) {
+		case core.AssignExpr:
+			if e.Operator.Type != core.COLON_EQ {
+				break
+			}
+			if fn, ok := e.Value.(core.FnExpr); ok {
+				add(e.Name, completionFunction, fn.Params, signature(e.Name, fn.Params), scope)
+			} else {
+				add(e.Name, completionVariable, nil, "variable "+e.Name.String(), scope)
+			}
+		case core.FnDeclExpr:
+			add(e.Name, completionFunction, e.Params, signature(e.Name, e.Params), scope)
+			function(e.Params, e.Body, e)
+			return false
+		case core.FnExpr:
+			function(e.Params, e.Body, e)
+			return false
+		case core.ImportExpr:
+			add(e.Alias(), completionModule, nil, e.String(), scope)
+		}
+		return true
+	})
+}
+
+// signature shows a function the way it is declared, e.g. "fn add(a, b)".
+func signature(name core.Token, params []core.Token) string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.String()
+	}
+	return fmt.Sprintf("fn %v(%s)", name, strings.Join(names, ", "))
+}
+
+// arity describes how many arguments a function takes.
+func arity(n int, variadic bool) string {
+	switch {
+	case variadic && n == 0:
+		return "Takes any number of arguments"
+	case variadic && n == 1:
+		return "Takes at least 1 argument"
+	case variadic:
+		return fmt.Sprintf("Takes at least %d arguments", n)
+	case n == 0:
+		return "Takes no arguments"
+	case n == 1:
+		return "Takes 1 argument"
+	default:
+		return fmt.Sprintf("Takes %d arguments", n)
+	}
+}
+
+// position converts a 1-based line and rune column to a Position. Lines
+// and columns past the end are clamped to it.
+func (doc *document) position(line int, column int) Position {
+	if line > len(doc.lines) {
+		line, column = len(doc.lines), len(doc.lines[len(doc.lines)-1])+1
+	}
+	if line < 1 {
+		return Position{}
+	}
+	runes := doc.lines[line-1]
+	column = min(max(column-1, 0), len(runes))
+	return Position{
+		Line:      line - 1,
+		Character: len(utf16.Encode(runes[:column])),
+	}
+}
+
+// location converts a Position to a 1-based line and rune column.
+func (doc *document) location(pos Position) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(doc.lines) {
+		return pos.Line + 1, 1
+	}
+	runes := doc.lines[pos.Line]
+	column, units := 0, 0
+	for column < len(runes) && units < pos.Character {
+		units += len(utf16.Encode(runes[column : column+1]))
+		column++
+	}
+	return pos.Line + 1, column + 1
+}
+
+// rangeOf returns the range span covers. A span may continue on the
+// following lines, like the span of a string with line breaks.
+func (doc *document) rangeOf(span core.Span) Range {
+	line, column := span.Line, span.Column
+	for n := span.Length; n > 0; n-- {
+		if line >= 1 && line <= len(doc.lines) && column > len(doc.lines[line-1]) {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	return Range{
+		Start: doc.position(span.Line, span.Column),
+		End:   doc.position(line, column),
+	}
+}
+
+// rangeOfExpr returns the range from the first to the last token of expr.
+func (doc *document) rangeOfExpr(expr core.Expr) Range {
+	var first, last core.Token
+	core.WalkTokens(expr, func(token core.Token) {
+		if token.Line == 0 {
+			return
+		}
+		if first.Line == 0 || before(token, first) {
+			first = token
+		}
+		if last.Line == 0 || before(last, token) {
+			last = token
+		}
+	})
+	return Range{
+		Start: doc.rangeOf(first.Span()).Start,
+		End:   doc.rangeOf(last.Span()).End,
+	}
+}
+
+func before(a core.Token, b core.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+func contains(r Range, pos Position) bool {
+	afterStart := pos.Line > r.Start.Line || pos.Line == r.Start.Line && pos.Character >= r.Start.Character
+	beforeEnd := pos.Line < r.End.Line || pos.Line == r.End.Line && pos.Character <= r.End.Character
+	return afterStart && beforeEnd
+}
+
+// end returns the position after the last character.
+func (doc *document) end() Position {
+	last := doc.lines[len(doc.lines)-1]
+	return Position{
+		Line:      len(doc.lines) - 1,
+		Character: len(utf16.Encode(last)),
+	}
+}
+
+// tokenAt returns the token under pos, or the one ending right before it
+// when the cursor is at the end of a word.
+func (doc *document) tokenAt(pos Position) (int, bool) {
+	line, column := doc.location(pos)
+	found := -1
+	for i, token := range doc.tokens {
+		if token.Type == core.EOF || token.Line != line {
+			continue
+		}
+		if token.Column <= column && column < token.Column+token.Length {
+			return i, true
+		}
+		if column == token.Column+token.Length {
+			found = i
+		}
+	}
+	return found, found >= 0
+}
+
+// identifierAt returns the identifier under pos.
+func (doc *document) identifierAt(pos Position) (core.Token, bool) {
+	i, ok := doc.tokenAt(pos)
+	if !ok || doc.tokens[i].Type != core.IDENTIFIER {
+		return core.Token{}, false
+	}
+	return doc.tokens[i], true
+}
+
+func sameToken(a core.Token, b core.Token) bool {
+	return a.Line == b.Line && a.Column == b.Column
+}
+
+// definition returns the token that declares the identifier at pos. A
+// declaration is its own definition.
+func (doc *document) definition(pos Position) (core.Token, bool) {
+	token, ok := doc.identifierAt(pos)
+	if !ok {
+		return core.Token{}, false
+	}
+	for _, reference := range doc.references {
+		if sameToken(reference.Name, token) {
+			return reference.Declaration, true
+		}
+	}
+	if _, ok := doc.declaration(token); ok {
+		return token, true
+	}
+	return core.Token{}, false
+}
+
+// declaration returns the declaration made by token.
+func (doc *document) declaration(token core.Token) (declaration, bool) {
+	for _, decl := range doc.declarations {
+		if sameToken(decl.name, token) {
+			return decl, true
+		}
+	}
+	return declaration{}, false
+}
+
+// hover describes the identifier at pos: a declaration of the document,
+// a builtin or a member of a builtin module.
+func (doc *document) hover(pos Position, interp *core.Interpreter) *Hover {
+	i, ok := doc.tokenAt(pos)
+	if !ok || doc.tokens[i].Type != core.IDENTIFIER {
+		return nil
+	}
+	token := doc.tokens[i]
+	r := doc.rangeOf(token.Span())
+
+	var text string
+	if definition, ok := doc.definition(pos); ok {
+		decl, _ := doc.declaration(definition)
+		text = describe(decl)
+	} else if i > 0 && doc.tokens[i-1].Type == core.DOT {
+		module, ok := doc.moduleBefore(i, interp)
+		if !ok {
+			return nil
+		}
+		member, ok := module.Get(token.String())
+		if !ok {
+			return nil
+		}
+		text = describeValue(module.Name+"."+token.String(), member)
+	} else if value, err := interp.Globals().Get(token.String()); err == nil {
+		text = describeValue(token.String(), value)
+	}
+	if text == "" {
+		return nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: text},
+		Range:    &r,
+	}
+}
+
+func describe(decl declaration) string {
+	text := "```lagn\n" + decl.detail + "\n```"
+	if decl.kind == completionFunction {
+		text += "\n" + arity(len(decl.params), false)
+	}
+	return text
+}
+
+func describeValue(name string, value any) string {
+	switch value := value.(type) {
+	case core.Function:
+		return fmt.Sprintf("```lagn\nfn %s\n```\nBuiltin, %s", name, strings.ToLower(arity(value.Arity, value.Variadic)))
+	case *core.Module:
+		return fmt.Sprintf("```lagn\nmodule %s\n```", value.Name)
+	default:
+		return fmt.Sprintf("```lagn\n%s = %s\n```", name, core.Inspect(value))
+	}
+}
+
+// moduleBefore returns the builtin module named before the '.' in front of
+// the token at index i, if there is one.
+func (doc *document) moduleBefore(i int, interp *core.Interpreter) (*core.Module, bool) {
+	if i < 2 || doc.tokens[i-1].Type != core.DOT || doc.tokens[i-2].Type != core.IDENTIFIER {
+		return nil, false
+	}
+	return doc.module(doc.tokens[i-2].String(), interp)
+}
+
+// module returns the builtin module the document imports as alias.
+func (doc *document) module(alias string, interp *core.Interpreter) (*core.Module, bool) {
+	for _, expr := range doc.program {
+		if imp, ok := expr.(core.ImportExpr); ok && imp.Alias().String() == alias {
+			return interp.NativeModule(imp.Path.Value.(string))
+		}
+	}
+	return nil, false
+}
+
+// symbols returns the declarations made directly in exprs, with the
+// declarations inside functions as their children.
+func (doc *document) symbols(exprs []core.Expr) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	function := func(name core.Token, params []core.Token, body core.Expr, expr core.Expr) {
+		symbols = append(symbols, DocumentSymbol{
+			Name:           name.String(),
+			Detail:         signature(name, params),
+			Kind:           symbolFunction,
+			Range:          doc.rangeOfExpr(expr),
+			SelectionRange: doc.rangeOf(name.Span()),
+			Children:       doc.symbols(statements(body)),
+		})
+	}
+
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case core.AssignExpr:
+			if e.Operator.Type != core.COLON_EQ {
+				continue
+			}
+			if fn, ok := e.Value.(core.FnExpr); ok {
+				function(e.Name, fn.Params, fn.Body, e)
+				continue
+			}
+			symbols = append(symbols, DocumentSymbol{
+				Name:           e.Name.String(),
+				Kind:           symbolVariable,
+				Range:          doc.rangeOfExpr(e),
+				SelectionRange: doc.rangeOf(e.Name.Span()),
+			})
+		case core.FnDeclExpr:
+			function(e.Name, e.Params, e.Body, e)
+		case core.ImportExpr:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           e.Alias().String(),
+				Detail:         e.Path.String(),
+				Kind:           symbolModule,
+				Range:          doc.rangeOfExpr(e),
+				SelectionRange: doc.rangeOf(e.Alias().Span()),
+			})
+		}
+	}
+	return symbols
+}
+
+// statements returns the statements of a function body.
+func statements(body core.Expr) []core.Expr {
+	if block, ok := body.(core.BlockExpr); ok {
+		return block.Body
+	}
+	return []core.Expr{body}
+}
+
+// complete returns the candidates for the word before pos: the members of
+// a builtin module after '.', otherwise keywords, the names declared in
+// the document that are visible at pos and builtins.
+func (doc *document) complete(pos Position, interp *core.Interpreter) []CompletionItem {
+	line, column := doc.location(pos)
+	if line < 1 || line > len(doc.lines) {
+		return []CompletionItem{}
+	}
+	runes := doc.lines[line-1]
+	start := column - 1
+	for start > 0 && isIdentifierRune(runes[start-1]) {
+		start--
+	}
+	word := string(runes[start : column-1])
+
+	items := []CompletionItem{}
+	if start > 0 && runes[start-1] == '.' {
+		object := start - 1
+		for object > 0 && isIdentifierRune(runes[object-1]) {
+			object--
+		}
+		module, ok := doc.module(string(runes[object:start-1]), interp)
+		if !ok {
+			return items
+		}
+		for _, name := range module.Members() {
+			if strings.HasPrefix(name, word) {
+				value, _ := module.Get(name)
+				items = append(items, valueItem(name, value))
+			}
+		}
+		return items
+	}
+
+	keywords := make([]string, 0, len(core.KEYWORDS))
+	for keyword := range core.KEYWORDS {
+		keywords = append(keywords, keyword)
+	}
+	slices.Sort(keywords)
+
+	seen := make(map[string]bool)
+	for _, keyword := range keywords {
+		if strings.HasPrefix(keyword, word) {
+			seen[keyword] = true
+			items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword})
+		}
+	}
+	for _, decl := range doc.declarations {
+		name := decl.name.String()
+		if !strings.HasPrefix(name, word) || seen[name] || decl.scope != nil && !contains(*decl.scope, pos) {
+			continue
+		}
+		seen[name] = true
+		items = append(items, CompletionItem{Label: name, Kind: decl.kind, Detail: decl.detail})
+	}
+	for env := interp.Globals(); env != nil; env = env.Enclosing() {
+		for _, name := range env.Names() {
+			if strings.HasPrefix(name, word) && !seen[name] {
+				seen[name] = true
+				value, _ := env.Get(name)
+				items = append(items, valueItem(name, value))
+			}
+		}
+	}
+	return items
+}
+
+func valueItem(name string, value any) CompletionItem {
+	if function, ok := value.(core.Function); ok {
+		return CompletionItem{Label: name, Kind: completionFunction, Detail: strings.TrimPrefix(function.String(), "f")}
+	}
+	return CompletionItem{Label: name, Kind: completionVariable}
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,358 @@
+// Package lsp implements a Language Server Protocol server for lagn. It
+// speaks JSON-RPC over any pair of streams, so an editor can run it on
+// stdin and stdout and a script can drive it with prepared messages.
+//
+// Documents are synchronized in full on every change and analyzed with
+// the scanner, parser and resolver. Diagnostics, go to definition, hover,
+// document symbols, completion and formatting are supported.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+// ErrNoShutdown is returned by Run when the client sends exit without
+// asking the server to shut down first.
+var ErrNoShutdown = errors.New("Exited without shutdown")
+
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+	// interp provides the builtins names are resolved against and
+	// completed from. No program is ever run in it.
+	interp    *core.Interpreter
+	documents map[string]*document
+
+	initialized bool
+	shutdown    bool
+}
+
+func New(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		interp:    core.NewInterpreter(),
+		documents: make(map[string]*document),
+	}
+}
+
+// method handles the params of a request and returns its result. The
+// result of a notification is dropped.
+type method func(s *Server, params json.RawMessage) (any, error)
+
+var methods map[string]method
+
+func init() {
+	methods = map[string]method{
+		"initialize":                  (*Server).initialize,
+		"initialized":                 ignore,
+		"shutdown":                    (*Server).shutdownRequest,
+		"textDocument/didOpen":        (*Server).didOpen,
+		"textDocument/didChange":      (*Server).didChange,
+		"textDocument/didSave":        ignore,
+		"textDocument/didClose":       (*Server).didClose,
+		"textDocument/definition":     (*Server).definition,
+		"textDocument/hover":          (*Server).hover,
+		"textDocument/documentSymbol": (*Server).documentSymbol,
+		"textDocument/completion":     (*Server).completion,
+		"textDocument/formatting":     (*Server).formatting,
+	}
+}
+
+func ignore(*Server, json.RawMessage) (any, error) {
+	return nil, nil
+}
+
+// Run serves messages until the client sends exit or the input ends. It
+// returns nil when the client asked the server to shut down before
+// exiting, ErrNoShutdown when it did not, or the error that ended the
+// input.
+func (s *Server) Run() error {
+	for {
+		body, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.write(errorResponse{
+				JSONRPC: "2.0",
+				ID:      json.RawMessage("null"),
+				Error:   &Error{Code: codeParseError, Message: err.Error()},
+			})
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrNoShutdown
+			}
+			return nil
+		}
+		s.handle(req)
+	}
+}
+
+// read returns the content of the next message, which is preceded by
+// headers of which only Content-Length is used.
+func (s *Server) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("Invalid Content-Length %q", strings.TrimSpace(value))
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("Missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	return body, err
+}
+
+func (s *Server) write(message any) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		body, _ = json.Marshal(notification{
+			JSONRPC: "2.0",
+			Method:  "window/logMessage",
+			Params:  map[string]any{"type": 1, "message": err.Error()},
+		})
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *Server) notify(name string, params any) {
+	s.write(notification{
+		JSONRPC: "2.0",
+		Method:  name,
+		Params:  params,
+	})
+}
+
+// handle runs the method of req and answers it unless it is a
+// notification. Unknown notifications are ignored.
+func (s *Server) handle(req request) {
+	isRequest := len(req.ID) > 0 && string(req.ID) != "null"
+
+	var result any
+	var err error
+	m, ok := methods[req.Method]
+	switch {
+	case !ok:
+		err = &Error{Code: codeMethodNotFound, Message: fmt.Sprintf("Unknown method %s", req.Method)}
+	case !s.initialized && req.Method != "initialize":
+		err = &Error{Code: codeServerNotInitialized, Message: "Server is not initialized"}
+	case s.shutdown:
+		err = &Error{Code: codeInvalidRequest, Message: "Server is shut down"}
+	default:
+		result, err = m(s, req.Params)
+	}
+	if !isRequest {
+		return
+	}
+
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: codeInternalError, Message: err.Error()}
+		}
+		s.write(errorResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr})
+		return
+	}
+	s.write(response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+// decode unmarshals params into v, failing with an invalid params error.
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &Error{Code: codeInvalidParams, Message: fmt.Sprintf("Document %s is not open", uri)}
+	}
+	return doc, nil
+}
+
+// update analyzes the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri string, text string) {
+	doc := newDocument(uri, text, s.interp.Globals())
+	s.documents[uri] = doc
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	if s.initialized {
+		return nil, &Error{Code: codeInvalidRequest, Message: "Server is already initialized"}
+	}
+	s.initialized = true
+	return map[string]any{
+		"capabilities": map[string]any{
+			// Every change sends the whole document.
+			"textDocumentSync":       1,
+			"definitionProvider":     true,
+			"hoverProvider":          true,
+			"documentSymbolProvider": true,
+			"completionProvider": map[string]any{
+				"triggerCharacters": []string{"."},
+			},
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]any{
+			"name": "lagn",
+		},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (any, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	s.update(p.TextDocument.URI, p.TextDocument.Text)
+	return nil, nil
+}
+
+func (s *Server) didChange(params json.RawMessage) (any, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	return nil, nil
+}
+
+func (s *Server) didClose(params json.RawMessage) (any, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.documents, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+	return nil, nil
+}
+
+// positionParams decodes params naming a position in an open document.
+func (s *Server) positionParams(params json.RawMessage) (*document, Position, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, Position{}, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	return doc, p.Position, err
+}
+
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	doc, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	token, ok := doc.definition(pos)
+	if !ok {
+		return nil, nil
+	}
+	return Location{URI: doc.uri, Range: doc.rangeOf(token.Span())}, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	doc, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if hover := doc.hover(pos, s.interp); hover != nil {
+		return hover, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p TextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return doc.symbols(doc.program), nil
+}
+
+func (s *Server) completion(params json.RawMessage) (any, error) {
+	doc, pos, err := s.positionParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return doc.complete(pos, s.interp), nil
+}
+
+// formatting replaces the whole document with its formatted source. A
+// document with syntax errors is left alone, the errors are already shown
+// as diagnostics.
+func (s *Server) formatting(params json.RawMessage) (any, error) {
+	var p TextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := core.Format(doc.uri, doc.text)
+	if err != nil {
+		return nil, nil
+	}
+	edits := []TextEdit{}
+	if formatted != doc.text {
+		edits = append(edits, TextEdit{
+			Range:   Range{End: doc.end()},
+			NewText: formatted,
+		})
+	}
+	return edits, nil
+}
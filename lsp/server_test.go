@@ -0,0 +1,468 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/SushiWaUmai/lagn/lsp"
+)
+
+// session is a scripted client. Messages are queued up front and the
+// server runs until it reads exit or the end of the script.
+type session struct {
+	in bytes.Buffer
+	id int
+}
+
+func (s *session) send(message map[string]any) {
+	message["jsonrpc"] = "2.0"
+	body, err := json.Marshal(message)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(&s.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// request queues a request and returns its id.
+func (s *session) request(method string, params any) int {
+	s.id++
+	s.send(map[string]any{"id": s.id, "method": method, "params": params})
+	return s.id
+}
+
+func (s *session) notify(method string, params any) {
+	s.send(map[string]any{"method": method, "params": params})
+}
+
+func (s *session) open(uri string, text string) {
+	s.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 1, "text": text},
+	})
+}
+
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *lsp.Error      `json:"error"`
+}
+
+// transcript holds what the server wrote.
+type transcript []message
+
+// run serves the script and returns the messages the server wrote and the
+// error Run returned.
+func (s *session) run(t *testing.T) (transcript, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	err := lsp.New(&s.in, &out).Run()
+
+	var messages transcript
+	reader := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, readErr := reader.ReadMIMEHeader()
+		if readErr == io.EOF {
+			break
+		} else if readErr != nil {
+			t.Fatalf("Reading the header of a message: %v", readErr)
+		}
+		length, convErr := strconv.Atoi(header.Get("Content-Length"))
+		if convErr != nil {
+			t.Fatalf("Invalid Content-Length: %v", convErr)
+		}
+		body := make([]byte, length)
+		if _, readErr := io.ReadFull(reader.R, body); readErr != nil {
+			t.Fatalf("Reading a message: %v", readErr)
+		}
+		var m message
+		if jsonErr := json.Unmarshal(body, &m); jsonErr != nil {
+			t.Fatalf("Invalid message %s: %v", body, jsonErr)
+		}
+		messages = append(messages, m)
+	}
+	return messages, err
+}
+
+// response decodes the result of request id into v.
+func (tr transcript) response(t *testing.T, id int, v any) {
+	t.Helper()
+
+	for _, m := range tr {
+		if string(m.ID) != strconv.Itoa(id) {
+			continue
+		}
+		if m.Error != nil {
+			t.Fatalf("Request %d failed: %d %s", id, m.Error.Code, m.Error.Message)
+		}
+		if err := json.Unmarshal(m.Result, v); err != nil {
+			t.Fatalf("Result of request %d: %v", id, err)
+		}
+		return
+	}
+	t.Fatalf("No response to request %d", id)
+}
+
+// errorOf returns the error request id was answered with.
+func (tr transcript) errorOf(t *testing.T, id int) *lsp.Error {
+	t.Helper()
+
+	for _, m := range tr {
+		if string(m.ID) == strconv.Itoa(id) {
+			if m.Error == nil {
+				t.Fatalf("Request %d succeeded with %s", id, m.Result)
+			}
+			return m.Error
+		}
+	}
+	t.Fatalf("No response to request %d", id)
+	return nil
+}
+
+// diagnostics returns the last diagnostics published for uri.
+func (tr transcript) diagnostics(t *testing.T, uri string) []lsp.Diagnostic {
+	t.Helper()
+
+	var found []lsp.Diagnostic
+	published := false
+	for _, m := range tr {
+		if m.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var params lsp.PublishDiagnosticsParams
+		if err := json.Unmarshal(m.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.URI == uri {
+			found, published = params.Diagnostics, true
+		}
+	}
+	if !published {
+		t.Fatalf("No diagnostics published for %s", uri)
+	}
+	return found
+}
+
+func at(line int, character int) map[string]any {
+	return map[string]any{"line": line, "character": character}
+}
+
+func positionParams(uri string, line int, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     at(line, character),
+	}
+}
+
+func documentParams(uri string) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}}
+}
+
+func span(startLine, startChar, endLine, endChar int) lsp.Range {
+	return lsp.Range{
+		Start: lsp.Position{Line: startLine, Character: startChar},
+		End:   lsp.Position{Line: endLine, Character: endChar},
+	}
+}
+
+const program = `import "math"
+
+x := 1
+fn area(r) {
+  x := r * r
+  return math.pi * x
+}
+print(area(x))
+`
+
+// serve runs a session that initializes the server, opens the program
+// as main.lagn and then queues what script adds, ending with a clean
+// shutdown.
+func serve(t *testing.T, script func(s *session)) transcript {
+	t.Helper()
+
+	s := &session{}
+	s.request("initialize", map[string]any{"capabilities": map[string]any{}})
+	s.notify("initialized", map[string]any{})
+	s.open("file:///main.lagn", program)
+	script(s)
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	tr, err := s.run(t)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return tr
+}
+
+func TestDiagnostics(t *testing.T) {
+	tr := serve(t, func(s *session) {
+		s.open("file:///undeclared.lagn", "x := 1\nprint(y)\n")
+		s.open("file:///broken.lagn", "x := (1 +\nprint(x\n")
+		s.open("file:///fixed.lagn", "print(y)\n")
+		s.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": "file:///fixed.lagn", "version": 2},
+			"contentChanges": []map[string]any{{"text": "y := 2\nprint(y)\n"}},
+		})
+	})
+
+	if got := tr.diagnostics(t, "file:///main.lagn"); len(got) != 0 {
+		t.Errorf("main.lagn has diagnostics %v", got)
+	}
+
+	got := tr.diagnostics(t, "file:///undeclared.lagn")
+	if len(got) != 1 {
+		t.Fatalf("Got diagnostics %v, want one", got)
+	}
+	if got[0].Message != "Undeclared variable y" || got[0].Range != span(1, 6, 1, 7) {
+		t.Errorf("Got %q at %v", got[0].Message, got[0].Range)
+	}
+
+	if got := tr.diagnostics(t, "file:///broken.lagn"); len(got) == 0 {
+		t.Errorf("broken.lagn has no diagnostics")
+	}
+	if got := tr.diagnostics(t, "file:///fixed.lagn"); len(got) != 0 {
+		t.Errorf("Diagnostics %v were not cleared after the fix", got)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	var local, global, param, function int
+	tr := serve(t, func(s *session) {
+		// The x in the return statement is the local that shadows the
+		// global x.
+		local = s.request("textDocument/definition", positionParams("file:///main.lagn", 5, 19))
+		global = s.request("textDocument/definition", positionParams("file:///main.lagn", 7, 11))
+		param = s.request("textDocument/definition", positionParams("file:///main.lagn", 4, 7))
+		function = s.request("textDocument/definition", positionParams("file:///main.lagn", 7, 7))
+	})
+
+	tests := []struct {
+		name string
+		id   int
+		want lsp.Range
+	}{
+		{"shadowing local", local, span(4, 2, 4, 3)},
+		{"global", global, span(2, 0, 2, 1)},
+		{"parameter", param, span(3, 8, 3, 9)},
+		{"function", function, span(3, 3, 3, 7)},
+	}
+	for _, test := range tests {
+		var location lsp.Location
+		tr.response(t, test.id, &location)
+		if location.URI != "file:///main.lagn" || location.Range != test.want {
+			t.Errorf("%s: got %v in %s, want %v", test.name, location.Range, location.URI, test.want)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	var function, member, builtin, nothing int
+	tr := serve(t, func(s *session) {
+		function = s.request("textDocument/hover", positionParams("file:///main.lagn", 7, 7))
+		member = s.request("textDocument/hover", positionParams("file:///main.lagn", 5, 15))
+		builtin = s.request("textDocument/hover", positionParams("file:///main.lagn", 7, 2))
+		nothing = s.request("textDocument/hover", positionParams("file:///main.lagn", 1, 0))
+	})
+
+	tests := []struct {
+		name string
+		id   int
+		want []string
+	}{
+		{"function", function, []string{"fn area(r)", "Takes 1 argument"}},
+		{"module member", member, []string{"math.pi = 3.14159"}},
+		{"builtin", builtin, []string{"fn print", "Builtin, takes 1 argument"}},
+	}
+	for _, test := range tests {
+		var hover lsp.Hover
+		tr.response(t, test.id, &hover)
+		for _, want := range test.want {
+			if !strings.Contains(hover.Contents.Value, want) {
+				t.Errorf("%s: hover %q does not contain %q", test.name, hover.Contents.Value, want)
+			}
+		}
+	}
+
+	var empty *lsp.Hover
+	tr.response(t, nothing, &empty)
+	if empty != nil {
+		t.Errorf("Hover on an empty line is %v, want null", empty)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	var id int
+	tr := serve(t, func(s *session) {
+		id = s.request("textDocument/documentSymbol", documentParams("file:///main.lagn"))
+	})
+
+	var symbols []lsp.DocumentSymbol
+	tr.response(t, id, &symbols)
+
+	var names []string
+	for _, symbol := range symbols {
+		names = append(names, symbol.Name)
+	}
+	if strings.Join(names, " ") != "math x area" {
+		t.Fatalf("Got symbols %v, want math x area", names)
+	}
+	area := symbols[2]
+	if area.Detail != "fn area(r)" || area.Range != span(3, 0, 6, 1) || area.SelectionRange != span(3, 3, 3, 7) {
+		t.Errorf("Got area %q at %v selecting %v", area.Detail, area.Range, area.SelectionRange)
+	}
+	if len(area.Children) != 1 || area.Children[0].Name != "x" {
+		t.Errorf("Got children %v of area, want the local x", area.Children)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	var members, prefix, scoped int
+	tr := serve(t, func(s *session) {
+		s.open("file:///member.lagn", "import \"math\"\nmath.sq")
+		members = s.request("textDocument/completion", positionParams("file:///member.lagn", 1, 7))
+		s.open("file:///prefix.lagn", "fn area(radius) {\n  ra\n}\nra")
+		prefix = s.request("textDocument/completion", positionParams("file:///prefix.lagn", 1, 4))
+		scoped = s.request("textDocument/completion", positionParams("file:///prefix.lagn", 3, 2))
+	})
+
+	labels := func(id int) []string {
+		var items []lsp.CompletionItem
+		tr.response(t, id, &items)
+		var labels []string
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	if got := labels(members); strings.Join(got, " ") != "sqrt" {
+		t.Errorf("Completing math.sq gave %v, want sqrt", got)
+	}
+	if got := labels(prefix); !contains(got, "radius") || !contains(got, "range") {
+		t.Errorf("Completing ra inside area gave %v, want radius and range", got)
+	}
+	if got := labels(scoped); contains(got, "radius") {
+		t.Errorf("Completing ra outside area gave %v, which includes the parameter", got)
+	}
+}
+
+func TestPositionOutOfRange(t *testing.T) {
+	var requests []int
+	var after int
+	tr := serve(t, func(s *session) {
+		s.open("file:///a.lagn", "x := 1\nx")
+		for _, pos := range [][2]int{{-1, 0}, {0, -3}, {-2, -2}, {7, 0}} {
+			for _, method := range []string{"textDocument/completion", "textDocument/hover", "textDocument/definition"} {
+				requests = append(requests, s.request(method, positionParams("file:///a.lagn", pos[0], pos[1])))
+			}
+		}
+		after = s.request("textDocument/completion", positionParams("file:///a.lagn", 1, 1))
+	})
+
+	// Every request is answered and the server keeps serving.
+	for _, id := range requests {
+		var result any
+		tr.response(t, id, &result)
+	}
+	var items []lsp.CompletionItem
+	tr.response(t, after, &items)
+	if len(items) == 0 {
+		t.Errorf("No completions after the requests out of range")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFormatting(t *testing.T) {
+	var messy, clean, broken int
+	tr := serve(t, func(s *session) {
+		s.open("file:///messy.lagn", "x:=1\nprint( x )")
+		messy = s.request("textDocument/formatting", documentParams("file:///messy.lagn"))
+		clean = s.request("textDocument/formatting", documentParams("file:///main.lagn"))
+		s.open("file:///broken.lagn", "print(")
+		broken = s.request("textDocument/formatting", documentParams("file:///broken.lagn"))
+	})
+
+	var edits []lsp.TextEdit
+	tr.response(t, messy, &edits)
+	if len(edits) != 1 || edits[0].Range != span(0, 0, 1, 10) || edits[0].NewText != "x := 1\nprint(x)\n" {
+		t.Errorf("Got edits %+v", edits)
+	}
+
+	edits = nil
+	tr.response(t, clean, &edits)
+	if edits == nil || len(edits) != 0 {
+		t.Errorf("Got edits %+v for a formatted document, want none", edits)
+	}
+
+	var none *[]lsp.TextEdit
+	tr.response(t, broken, &none)
+	if none != nil {
+		t.Errorf("Got edits %+v for a document with syntax errors, want null", *none)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := &session{}
+	early := s.request("textDocument/hover", positionParams("file:///main.lagn", 0, 0))
+	s.request("initialize", map[string]any{})
+	unknown := s.request("textDocument/rename", map[string]any{})
+	closed := s.request("textDocument/hover", positionParams("file:///missing.lagn", 0, 0))
+	s.request("shutdown", nil)
+	after := s.request("textDocument/hover", positionParams("file:///main.lagn", 0, 0))
+	s.notify("exit", nil)
+
+	tr, err := s.run(t)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   int
+		code int
+	}{
+		{"before initialize", early, -32002},
+		{"unknown method", unknown, -32601},
+		{"document not open", closed, -32602},
+		{"after shutdown", after, -32600},
+	}
+	for _, test := range tests {
+		if got := tr.errorOf(t, test.id); got.Code != test.code {
+			t.Errorf("%s: got code %d, want %d", test.name, got.Code, test.code)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	s := &session{}
+	s.request("initialize", map[string]any{})
+	s.notify("exit", nil)
+	if _, err := s.run(t); !errors.Is(err, lsp.ErrNoShutdown) {
+		t.Errorf("Exit without shutdown returned %v, want ErrNoShutdown", err)
+	}
+
+	s = &session{}
+	s.request("initialize", map[string]any{})
+	if _, err := s.run(t); err != io.EOF {
+		t.Errorf("Ending the input returned %v, want io.EOF", err)
+	}
+}
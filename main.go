@@ -1,73 +1,112 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/SushiWaUmai/lagn/compiler"
 	"github.com/SushiWaUmai/lagn/core"
+	"github.com/SushiWaUmai/lagn/lsp"
 )
 
-func run(line string, environment core.Environment) (any, error) {
-	scanner := core.CreateScanner(line)
-	scanner.ScanTokens()
-	parser := core.CreateParser(scanner.Tokens)
-	program, err := parser.Parse()
+var useVM = flag.Bool("vm", false, "compile to bytecode and run on the virtual machine")
+
+func run(ctx context.Context, file string, line string, interp *core.Interpreter) (any, error) {
+	program, err := interp.Parse(file, line)
 	if err != nil {
 		return nil, err
 	}
 
-	var output any
-	for _, expr := range program {
-		output, err = expr.Interpret(environment)
-    if err != nil {
-      return nil, err
-    }
+	if *useVM {
+		proto, err := compiler.Compile(program)
+		if err != nil {
+			return nil, err
+		}
+		vm := compiler.NewVM(interp.Globals())
+		return interp.ExecuteContext(ctx, func() (any, error) {
+			return vm.Run(proto)
+		})
 	}
 
-	return output, nil
+	return interp.RunContext(ctx, program)
 }
 
-func runFile() {
-	filePath := os.Args[1]
+// runFile runs the script at filePath with args as its arguments and
+// returns the exit code of the process: the code passed to os.exit, 0 on
+// success, and one of the sysexits codes if the script fails.
+func runFile(filePath string, args []string) int {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		fmt.Println(err)
-		return
+		return 66
 	}
 
-	environment := core.DefaultEnvironment()
-	_, err = run(string(content), environment)
+	interp := core.NewInterpreter()
+	interp.Args = args
+	err = interp.Set("args", args)
 	if err != nil {
 		fmt.Println(err)
+		return 70
 	}
-}
+	_, err = run(context.Background(), filePath, string(content), interp)
 
-func runPrompt() {
-	bufScanner := bufio.NewScanner(os.Stdin)
+	var exit *core.ExitError
+	if errors.As(err, &exit) {
+		return exit.Code
+	}
+	if err != nil {
+		fmt.Println(interp.FormatError(err, string(content)))
+		if isCompileError(err) {
+			return 65
+		}
+		return 70
+	}
+	return 0
+}
 
-	environment := core.DefaultEnvironment()
-	fmt.Print("> ")
-	for bufScanner.Scan() {
-		line := bufScanner.Text()
-		output, err := run(line, environment)
-		if err != nil {
-			fmt.Println(err)
-		} else {
-      fmt.Println(output)
-    }
+// isCompileError reports whether err was found before the script started
+// running.
+func isCompileError(err error) bool {
+	var scanErr *core.ScanError
+	var parseErr *core.ParseError
+	var resolveErr *core.ResolveError
+	var compileErr *compiler.CompileError
+	return errors.As(err, &scanErr) || errors.As(err, &parseErr) || errors.As(err, &resolveErr) || errors.As(err, &compileErr)
+}
 
-		fmt.Print("> ")
+// runLSP serves the Language Server Protocol on stdin and stdout until
+// the editor exits. The exit code is 1 if it did not shut the server down
+// first or closed the input without exiting.
+func runLSP() int {
+	err := lsp.New(os.Stdin, os.Stdout).Run()
+	if errors.Is(err, lsp.ErrNoShutdown) || err == io.EOF {
+		return 1
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	return 0
 }
 
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: lagn [script]")
-		os.Exit(64)
-	} else if len(os.Args) == 2 {
-		runFile()
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: lagn [--vm] [--] [script [args...]]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lagn fmt [--check | --write] [files...]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lagn lsp")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 && flag.Arg(0) == "fmt" {
+		os.Exit(runFmt(flag.Args()[1:]))
+	} else if flag.NArg() > 0 && flag.Arg(0) == "lsp" {
+		os.Exit(runLSP())
+	} else if flag.NArg() > 0 {
+		os.Exit(runFile(flag.Arg(0), flag.Args()[1:]))
 	} else {
-		runPrompt()
+		os.Exit(runPrompt())
 	}
 }
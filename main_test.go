@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"args.lagn":    `import "os" if (#args == 2 && args[1] == "b" && os.args()[0] == "a") os.exit(7)`,
+		"ok.lagn":      `x := 1`,
+		"syntax.lagn":  `x := )`,
+		"resolve.lagn": `print(y)`,
+		"runtime.lagn": `x := 1 / 0`,
+	}
+	for name, src := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		script string
+		args   []string
+		want   int
+	}{
+		{"args.lagn", []string{"a", "b"}, 7},
+		{"args.lagn", []string{}, 0},
+		{"ok.lagn", nil, 0},
+		{"syntax.lagn", nil, 65},
+		{"resolve.lagn", nil, 65},
+		{"runtime.lagn", nil, 70},
+		{"missing.lagn", nil, 66},
+	}
+
+	for _, vm := range []bool{false, true} {
+		*useVM = vm
+		for _, test := range tests {
+			if got := runFile(filepath.Join(dir, test.script), test.args); got != test.want {
+				t.Errorf("%s %v with vm=%v exited with %d, want %d", test.script, test.args, vm, got, test.want)
+			}
+		}
+	}
+	*useVM = false
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/SushiWaUmai/lagn/core"
+	"github.com/SushiWaUmai/lagn/editor"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = ". "
+)
+
+type repl struct {
+	interp *core.Interpreter
+	editor *editor.Editor
+}
+
+// metaCommand is a REPL command starting with ':'. arg is the rest of the
+// line after the command name.
+type metaCommand struct {
+	usage string
+	help  string
+	run   func(r *repl, arg string) error
+}
+
+var metaCommands map[string]metaCommand
+
+func init() {
+	metaCommands = map[string]metaCommand{
+		"help":   {":help", "show this help", (*repl).help},
+		"env":    {":env", "list the global variables", (*repl).env},
+		"ast":    {":ast <code>", "show the syntax tree of code", (*repl).ast},
+		"tokens": {":tokens <code>", "show the tokens of code", (*repl).tokens},
+		"load":   {":load <file>", "run a file in the current session", (*repl).load},
+		"reset":  {":reset", "forget every global variable and module", (*repl).reset},
+		"quit": {":quit", "leave the REPL", func(*repl, string) error {
+			return errQuit
+		}},
+	}
+}
+
+// errQuit ends the REPL from a meta-command.
+var errQuit = errors.New("quit")
+
+// runPrompt reads and evaluates input until it ends or a meta-command or
+// os.exit leaves the REPL, and returns the exit code of the process.
+func runPrompt() int {
+	r := &repl{
+		interp: core.NewInterpreter(),
+		editor: editor.New(os.Stdin, os.Stdout),
+	}
+	r.editor.Complete = r.complete
+	if r.editor.IsTerminal() {
+		if path := historyPath(); path != "" {
+			if err := r.editor.LoadHistory(path); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+
+	for {
+		source, err := r.read()
+		if errors.Is(err, editor.ErrInterrupted) {
+			continue
+		} else if err != nil {
+			if err != io.EOF {
+				fmt.Println(err)
+			}
+			return 0
+		}
+
+		var output any
+		if strings.HasPrefix(strings.TrimSpace(source), ":") {
+			err = r.meta(strings.TrimSpace(source))
+		} else {
+			output, err = r.eval("", source)
+		}
+
+		var exit *core.ExitError
+		if errors.As(err, &exit) {
+			return exit.Code
+		} else if err == errQuit {
+			return 0
+		} else if err != nil {
+			fmt.Println(err)
+		} else if output != nil {
+			fmt.Println(core.Inspect(output))
+		}
+	}
+}
+
+// historyPath returns the file the REPL history is kept in, set by
+// LAGN_HISTORY and defaulting to ~/.lagn_history.
+func historyPath() string {
+	if path, ok := os.LookupEnv("LAGN_HISTORY"); ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lagn_history")
+}
+
+// read reads one complete input, prompting for more lines for as long as
+// the input so far ends in the middle of an expression. An empty line
+// ends the input regardless, so a mistake can always be submitted.
+func (r *repl) read() (string, error) {
+	var lines []string
+	for {
+		p := prompt
+		if len(lines) > 0 {
+			p = continuationPrompt
+		}
+		line, err := r.editor.ReadLine(p)
+		if err != nil {
+			return "", err
+		}
+		if err := r.editor.AddHistory(line); err != nil {
+			fmt.Println(err)
+		}
+
+		lines = append(lines, line)
+		source := strings.Join(lines, "\n")
+		if len(lines) == 1 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			return source, nil
+		}
+		if strings.TrimSpace(line) == "" || !incomplete(source) {
+			return source, nil
+		}
+	}
+}
+
+// incomplete reports whether source stops in the middle of an expression,
+// such as inside a string or a block, or after an operator.
+func incomplete(source string) bool {
+	scanner := core.CreateScanner(source)
+	err := scanner.ScanTokens()
+	if err != nil {
+		var scanErr *core.ScanError
+		return errors.As(err, &scanErr) && scanErr.Message == "Unterminated String"
+	}
+
+	eof := scanner.Tokens[len(scanner.Tokens)-1].Span()
+	parser := core.CreateParser(scanner.Tokens)
+	_, err = parser.Parse()
+	if err == nil {
+		return false
+	}
+	span, ok := core.ErrorSpan(err)
+	return ok && span.Line == eof.Line && span.Column == eof.Column
+}
+
+// eval runs source and returns its value. Errors come back formatted with
+// their line of source, except an ExitError, which is returned as is for
+// the REPL to exit with. Ctrl-C stops the evaluation instead of the REPL
+// while it runs.
+func (r *repl) eval(file string, source string) (any, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	output, err := run(ctx, file, source, r.interp)
+	stop()
+
+	if err != nil && !core.IsExit(err) {
+		return nil, errors.New(r.interp.FormatError(err, source))
+	}
+	return output, err
+}
+
+func (r *repl) meta(line string) error {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	arg = strings.TrimSpace(arg)
+	if name == "q" {
+		name = "quit"
+	}
+
+	command, ok := metaCommands[name]
+	if !ok {
+		return fmt.Errorf("Unknown command :%s, see :help", name)
+	}
+	return command.run(r, arg)
+}
+
+func (r *repl) help(string) error {
+	names := []string{"env", "ast", "tokens", "load", "reset", "help", "quit"}
+	for _, name := range names {
+		command := metaCommands[name]
+		fmt.Printf("  %-16s %s\n", command.usage, command.help)
+	}
+	return nil
+}
+
+func (r *repl) env(string) error {
+	globals := r.interp.Globals()
+	for _, name := range globals.Names() {
+		value, err := globals.Get(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s = %s\n", name, core.Inspect(value))
+	}
+	return nil
+}
+
+func (r *repl) ast(source string) error {
+	scanner := core.CreateScanner(source)
+	err := scanner.ScanTokens()
+	if err != nil {
+		return errors.New(core.FormatError(err, source))
+	}
+	parser := core.CreateParser(scanner.Tokens)
+	program, err := parser.Parse()
+	if err != nil {
+		return errors.New(core.FormatError(err, source))
+	}
+	for _, expr := range program {
+		fmt.Println(expr.String())
+	}
+	return nil
+}
+
+func (r *repl) tokens(source string) error {
+	scanner := core.CreateScanner(source)
+	err := scanner.ScanTokens()
+	for _, token := range scanner.Tokens {
+		if token.Value == nil {
+			fmt.Printf("%d:%d\t%v\n", token.Line, token.Column, token.Type)
+		} else {
+			fmt.Printf("%d:%d\t%v\t%v\n", token.Line, token.Column, token.Type, token)
+		}
+	}
+	if err != nil {
+		return errors.New(core.FormatError(err, source))
+	}
+	return nil
+}
+
+func (r *repl) load(path string) error {
+	if path == "" {
+		return errors.New("Usage: :load <file>")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	_, err = r.eval(path, string(content))
+	return err
+}
+
+func (r *repl) reset(string) error {
+	r.interp = core.NewInterpreter()
+	return nil
+}
+
+// complete offers meta-commands at the start of the line, the members of
+// a module after '.', and keywords, globals and builtins everywhere else.
+// Functions are hinted with their arity.
+func (r *repl) complete(line []rune, cursor int) (int, []editor.Completion) {
+	start := cursor
+	for start > 0 && isIdentifierRune(line[start-1]) {
+		start--
+	}
+	word := string(line[start:cursor])
+
+	if start == 1 && line[0] == ':' {
+		var completions []editor.Completion
+		for name := range metaCommands {
+			if strings.HasPrefix(name, word) {
+				completions = append(completions, editor.Completion{Text: name})
+			}
+		}
+		return start, sortCompletions(completions)
+	}
+
+	if start > 0 && line[start-1] == '.' {
+		objectStart := start - 1
+		for objectStart > 0 && isIdentifierRune(line[objectStart-1]) {
+			objectStart--
+		}
+		object, err := r.interp.Get(string(line[objectStart : start-1]))
+		module, ok := object.(*core.Module)
+		if err != nil || !ok {
+			return start, nil
+		}
+
+		var completions []editor.Completion
+		for _, name := range module.Members() {
+			if strings.HasPrefix(name, word) {
+				value, _ := module.Get(name)
+				completions = append(completions, completion(name, value))
+			}
+		}
+		return start, completions
+	}
+
+	seen := make(map[string]bool)
+	var completions []editor.Completion
+	for keyword := range core.KEYWORDS {
+		if strings.HasPrefix(keyword, word) {
+			seen[keyword] = true
+			completions = append(completions, editor.Completion{Text: keyword})
+		}
+	}
+	// Globals shadow builtins of the same name.
+	for env := r.interp.Globals(); env != nil; env = env.Enclosing() {
+		for _, name := range env.Names() {
+			if strings.HasPrefix(name, word) && !seen[name] {
+				seen[name] = true
+				value, _ := env.Get(name)
+				completions = append(completions, completion(name, value))
+			}
+		}
+	}
+	return start, sortCompletions(completions)
+}
+
+// completion hints a Function with its arity, e.g. "(2)" for a function
+// of two parameters.
+func completion(name string, value any) editor.Completion {
+	if function, ok := value.(core.Function); ok {
+		return editor.Completion{Text: name, Hint: strings.TrimPrefix(function.String(), "f")}
+	}
+	return editor.Completion{Text: name}
+}
+
+func sortCompletions(completions []editor.Completion) []editor.Completion {
+	slices.SortFunc(completions, func(a editor.Completion, b editor.Completion) int {
+		return strings.Compare(a.Text, b.Text)
+	})
+	return completions
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SushiWaUmai/lagn/core"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := map[string]bool{
+		`x := 1`:                      false,
+		`fn f() {`:                    true,
+		"fn f() {\n  return 1":        true,
+		"fn f() {\n  return 1\n}":     false,
+		`x := "abc`:                   true,
+		`x := 1 +`:                    true,
+		`print(1,`:                    true,
+		`x := )`:                      false,
+		`x := @`:                      false,
+		`if (a) print(1) else`:        true,
+		`m := {"a": 1,`:               true,
+		"while (true) {\n  break\n}}": false,
+	}
+	for src, want := range tests {
+		if got := incomplete(src); got != want {
+			t.Errorf("incomplete(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	r := &repl{interp: core.NewInterpreter()}
+
+	if value, err := r.eval("", `x := 20 x + 1`); err != nil || value != int64(21) {
+		t.Errorf("Got %v, %v, want 21", value, err)
+	}
+
+	// Errors come back with their line of source.
+	_, err := r.eval("", "x +\n  true")
+	if err == nil || !strings.Contains(err.Error(), "   1 | x +") {
+		t.Errorf("Got %v, want the error with its source", err)
+	}
+
+	// os.exit is handed back to the REPL loop instead of exiting.
+	_, err = r.eval("", `import "os" os.exit(3)`)
+	var exit *core.ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Errorf("Got %v, want an ExitError with code 3", err)
+	}
+}
+
+func TestMeta(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "exit.lagn")
+	if err := os.WriteFile(path, []byte(`import "os" os.exit(5)`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &repl{interp: core.NewInterpreter()}
+	var exit *core.ExitError
+	if err := r.meta(":load " + path); !errors.As(err, &exit) || exit.Code != 5 {
+		t.Errorf(":load got %v, want an ExitError with code 5", err)
+	}
+	if err := r.meta(":load"); err == nil {
+		t.Errorf(":load without a file succeeded")
+	}
+	if err := r.meta(":q"); err != errQuit {
+		t.Errorf(":q got %v, want errQuit", err)
+	}
+	if err := r.meta(":nope"); err == nil || err.Error() != "Unknown command :nope, see :help" {
+		t.Errorf(":nope got %v", err)
+	}
+
+	if _, err := r.eval("", `y := 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.meta(":reset"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.eval("", `y`); err == nil {
+		t.Errorf("y survived :reset")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	r := &repl{interp: core.NewInterpreter()}
+	if _, err := r.eval("", `import "math" fn printAll(a, b) nil primes := [2, 3]`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line  string
+		start int
+		want  string
+	}{
+		{"pri", 0, "primes print(1) printAll(2)"},
+		{"x := wh", 5, "while"},
+		{"math.sq", 5, "sqrt(1)"},
+		{"math.", 5, strings.Join(mathMembers(t, r), " ")},
+		{"nope.x", 5, ""},
+		{":re", 1, "reset"},
+		{"zzz", 0, ""},
+	}
+	for _, test := range tests {
+		line := []rune(test.line)
+		start, completions := r.complete(line, len(line))
+		var got []string
+		for _, completion := range completions {
+			got = append(got, completion.Text+completion.Hint)
+		}
+		if start != test.start || strings.Join(got, " ") != test.want {
+			t.Errorf("complete(%q) = %d, %v, want %d, %s", test.line, start, got, test.start, test.want)
+		}
+	}
+}
+
+func mathMembers(t *testing.T, r *repl) []string {
+	t.Helper()
+
+	module, ok := r.interp.NativeModule("math")
+	if !ok {
+		t.Fatal("No math module")
+	}
+	var members []string
+	for _, name := range module.Members() {
+		value, _ := module.Get(name)
+		c := completion(name, value)
+		members = append(members, c.Text+c.Hint)
+	}
+	return members
+}